@@ -3,34 +3,144 @@ package config
 
 import (
 	"database/sql"
+	goerror "errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net/url"
 	"os"
 
-	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+// Config is every setting of the server. Load reads it once at startup and
+// it is passed on from there; nothing reads the environment later.
 type Config struct {
-	Port           string
-	AllowedOrigins string
+	Environment string
+	Server      Server
+	Database    Database
+	Secrets     Secrets
+	Mailer      Mailer
+	Scheduler   Scheduler
+	LDAP        *LDAP
+	OAuth       []OAuthProvider
+	SAML        SAML
 }
 
-func InitDB() *sql.DB {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	sslmode := os.Getenv("DB_SSL")
+type Database struct {
+	Host           string
+	Port           int
+	User           string
+	Password       string
+	Name           string
+	SSLMode        string
+	MigrateOnStart bool
+}
+
+// Secrets sign tokens and cookies. LinkToken may be empty, in which case a
+// key is derived from JWTAccess.
+type Secrets struct {
+	JWTAccess  string
+	JWTRefresh string
+	LinkToken  string
+	Session    string
+}
+
+// Production reports whether ENVIRONMENT is production, which makes cookies
+// secure.
+func (c *Config) Production() bool {
+	return c.Environment == "production"
+}
+
+// Load reads the configuration from the environment, the .env file and the
+// YAML or TOML file named by CONFIG_FILE, in that order of precedence. It
+// returns an *Error listing every problem when the configuration is invalid.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil && !goerror.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %v", err)
+	}
+
+	src, err := newSource(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Environment: src.str("ENVIRONMENT", "development"),
+		Database: Database{
+			Host:           src.str("DB_HOST", ""),
+			Port:           src.integer("DB_PORT", 5432),
+			User:           src.str("DB_USER", ""),
+			Password:       src.str("DB_PASSWORD", ""),
+			Name:           src.str("DB_NAME", ""),
+			SSLMode:        src.str("DB_SSL", "disable"),
+			MigrateOnStart: src.boolean("MIGRATE_ON_START", true),
+		},
+		Secrets: Secrets{
+			JWTAccess:  src.str("JWT_ACCESS_SECRET", ""),
+			JWTRefresh: src.str("JWT_REFRESH_SECRET", ""),
+			LinkToken:  src.str("LINK_TOKEN_SECRET", ""),
+			Session:    src.str("SESSION_SECRET", ""),
+		},
+		Scheduler: loadScheduler(src),
+		LDAP:      loadLDAP(src),
+		OAuth:     loadOAuthProviders(src),
+		SAML:      loadSAML(src),
+	}
+	cfg.Server = loadServer(src, cfg.Production())
+	cfg.Mailer = loadMailer(src, cfg.Server.FrontendURL)
+
+	cfg.validate(src)
+	if len(src.problems) > 0 {
+		return nil, &Error{Problems: src.problems}
+	}
+	return cfg, nil
+}
 
-	if host == "" || port == "" || user == "" || password == "" || dbname == "" {
-		log.Fatal("Database connection parameters are not set in environment variables")
+func (c *Config) validate(src *source) {
+	required := map[string]string{
+		"DB_HOST":            c.Database.Host,
+		"DB_USER":            c.Database.User,
+		"DB_PASSWORD":        c.Database.Password,
+		"DB_NAME":            c.Database.Name,
+		"JWT_ACCESS_SECRET":  c.Secrets.JWTAccess,
+		"JWT_REFRESH_SECRET": c.Secrets.JWTRefresh,
+	}
+	for key, value := range required {
+		if value == "" {
+			src.problem("%s is required", key)
+		}
 	}
 
+	if c.Secrets.JWTAccess != "" && c.Secrets.JWTAccess == c.Secrets.JWTRefresh {
+		src.problem("JWT_ACCESS_SECRET and JWT_REFRESH_SECRET must differ")
+	}
+	if (len(c.OAuth) > 0 || len(c.SAML.Connections) > 0) && c.Secrets.Session == "" {
+		src.problem("SESSION_SECRET is required when OAuth providers or SAML connections are configured")
+	}
+
+	for key, value := range map[string]string{"BASE_URL": c.Server.BaseURL, "FRONTEND_URL": c.Server.FrontendURL} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			src.problem("%s must be an http or https URL, got %q", key, value)
+		}
+	}
+
+	c.Server.validate(src)
+	c.Mailer.validate(src)
+	for _, provider := range c.OAuth {
+		provider.validate(src)
+	}
+	c.SAML.validate(src)
+}
+
+func InitDB(cfg Database) *sql.DB {
 	db, err := sql.Open("postgres", fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode,
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode,
 	))
 	if err != nil {
 		log.Fatal("Error connecting to the database:", err)
@@ -40,9 +150,5 @@ func InitDB() *sql.DB {
 		log.Fatal("Error connecting to the database:", err)
 	}
 
-	if err = utils.AutoMigrate(db); err != nil {
-		log.Fatal("Error migrating the database:", err)
-	}
-
 	return db
 }
@@ -0,0 +1,53 @@
+package config
+
+import "strings"
+
+// LDAP configures the directory login backend. It is nil when LDAP_URL is
+// not set.
+type LDAP struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	EmailAttribute     string
+	UsernameAttribute  string
+	GroupAttribute     string
+	GroupRoles         map[string]string
+}
+
+func loadLDAP(src *source) *LDAP {
+	url := src.str("LDAP_URL", "")
+	if url == "" {
+		return nil
+	}
+
+	cfg := &LDAP{
+		URL:                url,
+		StartTLS:           src.boolean("LDAP_START_TLS", false),
+		InsecureSkipVerify: src.boolean("LDAP_INSECURE_SKIP_VERIFY", false),
+		BindDN:             src.str("LDAP_BIND_DN", ""),
+		BindPassword:       src.str("LDAP_BIND_PASSWORD", ""),
+		BaseDN:             src.str("LDAP_BASE_DN", ""),
+		UserFilter:         src.str("LDAP_USER_FILTER", "(mail=%s)"),
+		EmailAttribute:     src.str("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		UsernameAttribute:  src.str("LDAP_USERNAME_ATTRIBUTE", "uid"),
+		GroupAttribute:     src.str("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		GroupRoles:         map[string]string{},
+	}
+
+	// LDAP_GROUP_ROLES is a semicolon separated list of group_dn=role pairs.
+	// Group DNs contain commas and '=', so the role follows the last '='.
+	for _, pair := range strings.Split(src.str("LDAP_GROUP_ROLES", ""), ";") {
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			continue
+		}
+		group := strings.ToLower(strings.TrimSpace(pair[:i]))
+		cfg.GroupRoles[group] = strings.TrimSpace(pair[i+1:])
+	}
+
+	return cfg
+}
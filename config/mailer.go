@@ -0,0 +1,65 @@
+package config
+
+// Mailer selects and configures the mail transport and the branding of the
+// email templates. EMAIL and PASSWORD are
+// still read as the SMTP credentials and sender when the SMTP_* and
+// MAIL_FROM variables are not set.
+type Mailer struct {
+	Transport    string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+	Dir          string
+
+	TemplatesDir  string
+	DefaultLocale string
+	ProductName   string
+	LogoURL       string
+	PrimaryColor  string
+	SupportEmail  string
+	// BaseURL is the frontend (FRONTEND_URL) that links in emails point
+	// at; its pages call the API.
+	BaseURL string
+}
+
+func loadMailer(src *source, frontendURL string) Mailer {
+	return Mailer{
+		Transport:    src.str("MAIL_TRANSPORT", "smtp"),
+		From:         src.str("MAIL_FROM", src.str("EMAIL", "")),
+		SMTPHost:     src.str("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:     src.integer("SMTP_PORT", 587),
+		SMTPUsername: src.str("SMTP_USERNAME", src.str("EMAIL", "")),
+		SMTPPassword: src.str("SMTP_PASSWORD", src.str("PASSWORD", "")),
+		SMTPTLS:      src.str("SMTP_TLS", "starttls"),
+		Dir:          src.str("MAIL_DIR", "mail"),
+
+		TemplatesDir:  src.str("MAIL_TEMPLATES_DIR", ""),
+		DefaultLocale: src.str("MAIL_DEFAULT_LOCALE", "en"),
+		ProductName:   src.str("MAIL_PRODUCT_NAME", "auth-go"),
+		LogoURL:       src.str("MAIL_LOGO_URL", ""),
+		PrimaryColor:  src.str("MAIL_PRIMARY_COLOR", "#2563eb"),
+		SupportEmail:  src.str("MAIL_SUPPORT_EMAIL", ""),
+		BaseURL:       frontendURL,
+	}
+}
+
+func (m Mailer) validate(src *source) {
+	switch m.Transport {
+	case "smtp":
+		if m.From == "" {
+			src.problem("MAIL_FROM or EMAIL is required when MAIL_TRANSPORT is smtp")
+		}
+		if m.SMTPPort < 1 || m.SMTPPort > 65535 {
+			src.problem("SMTP_PORT must be between 1 and 65535, got %d", m.SMTPPort)
+		}
+		if m.SMTPTLS != "starttls" && m.SMTPTLS != "tls" && m.SMTPTLS != "none" {
+			src.problem("SMTP_TLS must be starttls, tls or none, got %q", m.SMTPTLS)
+		}
+	case "file", "log":
+	default:
+		src.problem("MAIL_TRANSPORT must be smtp, file or log, got %q", m.Transport)
+	}
+}
@@ -1,40 +1,176 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/apple"
 	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/gitlab"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/microsoftonline"
+	"github.com/markbates/goth/providers/openidConnect"
 )
 
-func InitOAuth() {
-	baseURL := os.Getenv("BASE_URL")
+// OAuthProvider describes one entry of the OAUTH_PROVIDERS list. Name is the
+// :provider route parameter, Type selects the implementation.
+type OAuthProvider struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	DiscoveryURL string
+}
 
-	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
+// InitOAuth registers the providers of cfg.OAuth with goth.
+func InitOAuth(cfg *Config) {
+	store := sessions.NewCookieStore([]byte(cfg.Secrets.Session))
 	store.MaxAge(86400 * 30) // 30 days
 	store.Options.Path = "/"
 	store.Options.HttpOnly = true
-	store.Options.Secure = false
+	store.Options.Secure = cfg.Server.SecureCookies
 	store.Options.SameSite = http.SameSiteLaxMode
 
 	gothic.Store = store
+	gothic.GetProviderName = providerFromContext
+
+	var providers []goth.Provider
+	for _, p := range cfg.OAuth {
+		provider, err := newOAuthProvider(p, cfg.Server.BaseURL+"/api/oauth/"+p.Name+"/callback")
+		if err != nil {
+			log.Fatalf("Error configuring OAuth provider %q: %v", p.Name, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	goth.UseProviders(providers...)
+}
+
+// loadOAuthProviders reads the comma separated OAUTH_PROVIDERS list and the
+// OAUTH_<NAME>_* settings of every entry. Without OAUTH_PROVIDERS the legacy
+// GITHUB_* and GOOGLE_* settings are used.
+func loadOAuthProviders(src *source) []OAuthProvider {
+	names := src.list("OAUTH_PROVIDERS")
+	if len(names) == 0 {
+		return legacyOAuthProviders(src)
+	}
 
-	goth.UseProviders(
-		github.New(
-			os.Getenv("GITHUB_CLIENT_ID"),
-			os.Getenv("GITHUB_CLIENT_SECRET"),
-			baseURL+"/api/auth/github/callback",
-			"user:email",
-		),
-		google.New(
-			os.Getenv("GOOGLE_CLIENT_ID"),
-			os.Getenv("GOOGLE_CLIENT_SECRET"),
-			baseURL+"/api/auth/google/callback",
-		),
-	)
+	var providers []OAuthProvider
+	for _, name := range names {
+		name = strings.ToLower(name)
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		providers = append(providers, OAuthProvider{
+			Name:         name,
+			Type:         strings.ToLower(src.str(prefix+"TYPE", name)),
+			ClientID:     src.str(prefix+"CLIENT_ID", ""),
+			ClientSecret: src.str(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.FieldsFunc(src.str(prefix+"SCOPES", ""), isScopeSeparator),
+			DiscoveryURL: src.str(prefix+"DISCOVERY_URL", ""),
+		})
+	}
+	return providers
+}
+
+func legacyOAuthProviders(src *source) []OAuthProvider {
+	var providers []OAuthProvider
+	if id := src.str("GITHUB_CLIENT_ID", ""); id != "" {
+		providers = append(providers, OAuthProvider{
+			Name:         "github",
+			Type:         "github",
+			ClientID:     id,
+			ClientSecret: src.str("GITHUB_CLIENT_SECRET", ""),
+		})
+	}
+	if id := src.str("GOOGLE_CLIENT_ID", ""); id != "" {
+		providers = append(providers, OAuthProvider{
+			Name:         "google",
+			Type:         "google",
+			ClientID:     id,
+			ClientSecret: src.str("GOOGLE_CLIENT_SECRET", ""),
+		})
+	}
+	return providers
+}
+
+func (p OAuthProvider) validate(src *source) {
+	prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_")) + "_"
+	if p.ClientID == "" {
+		src.problem("%sCLIENT_ID is required", prefix)
+	}
+	switch p.Type {
+	case "github", "google", "gitlab", "microsoft", "apple":
+	case "oidc":
+		if p.DiscoveryURL == "" {
+			src.problem("%sDISCOVERY_URL is required for oidc providers", prefix)
+		}
+	default:
+		src.problem("%sTYPE %q is not a known provider type", prefix, p.Type)
+	}
+}
+
+func newOAuthProvider(p OAuthProvider, callbackURL string) (goth.Provider, error) {
+	if p.ClientID == "" {
+		return nil, fmt.Errorf("client id is not set")
+	}
+
+	var provider goth.Provider
+	switch p.Type {
+	case "github":
+		scopes := p.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{"user:email"}
+		}
+		provider = github.New(p.ClientID, p.ClientSecret, callbackURL, scopes...)
+	case "google":
+		provider = newPKCEProvider(google.New(p.ClientID, p.ClientSecret, callbackURL, p.Scopes...), p, callbackURL, google.Endpoint.TokenURL)
+	case "gitlab":
+		provider = newPKCEProvider(gitlab.New(p.ClientID, p.ClientSecret, callbackURL, p.Scopes...), p, callbackURL, gitlab.TokenURL)
+	case "microsoft":
+		provider = newPKCEProvider(microsoftonline.New(p.ClientID, p.ClientSecret, callbackURL, p.Scopes...), p, callbackURL, microsoftTokenURL)
+	case "apple":
+		scopes := p.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{apple.ScopeName, apple.ScopeEmail}
+		}
+		provider = apple.New(p.ClientID, p.ClientSecret, callbackURL, nil, scopes...)
+	case "oidc":
+		if p.DiscoveryURL == "" {
+			return nil, fmt.Errorf("discovery url is required for oidc providers")
+		}
+		scopes := p.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{"profile", "email"}
+		}
+		oidc, err := openidConnect.NewNamed(p.Name, p.ClientID, p.ClientSecret, callbackURL, p.DiscoveryURL, scopes...)
+		if err != nil {
+			return nil, err
+		}
+		provider = oidc
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", p.Type)
+	}
+
+	provider.SetName(p.Name)
+	return provider, nil
+}
+
+// providerFromContext only trusts the provider that OAuthMiddleware put on
+// the request context, so concurrent logins never share provider state.
+func providerFromContext(req *http.Request) (string, error) {
+	if provider, ok := req.Context().Value(gothic.ProviderParamKey).(string); ok && provider != "" {
+		return provider, nil
+	}
+	return "", errors.New("you must select a provider")
+}
 
+func isScopeSeparator(r rune) bool {
+	return r == ',' || r == ' '
 }
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/gitlab"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/microsoftonline"
+	"golang.org/x/oauth2"
+)
+
+// microsoftTokenURL is the token endpoint goth's microsoftonline provider
+// uses, which it does not export.
+const microsoftTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+
+// PKCEProvider adds PKCE to a provider whose goth session does not forward
+// the code_verifier to the token endpoint. The callback's code_verifier
+// parameter is sent with the code exchange; without one the wrapped
+// provider exchanges the code as usual.
+type PKCEProvider struct {
+	goth.Provider
+	config *oauth2.Config
+}
+
+func newPKCEProvider(provider goth.Provider, p OAuthProvider, callbackURL, tokenURL string) *PKCEProvider {
+	return &PKCEProvider{
+		Provider: provider,
+		config: &oauth2.Config{
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  callbackURL,
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenURL},
+		},
+	}
+}
+
+func (p *PKCEProvider) BeginAuth(state string) (goth.Session, error) {
+	session, err := p.Provider.BeginAuth(state)
+	if err != nil {
+		return nil, err
+	}
+	return &pkceSession{Session: session}, nil
+}
+
+func (p *PKCEProvider) UnmarshalSession(data string) (goth.Session, error) {
+	session, err := p.Provider.UnmarshalSession(data)
+	if err != nil {
+		return nil, err
+	}
+	return &pkceSession{Session: session}, nil
+}
+
+func (p *PKCEProvider) FetchUser(session goth.Session) (goth.User, error) {
+	if s, ok := session.(*pkceSession); ok {
+		session = s.Session
+	}
+	return p.Provider.FetchUser(session)
+}
+
+// client is the HTTP client of the wrapped provider, which tests point at
+// a stub.
+func (p *PKCEProvider) client() *http.Client {
+	if c, ok := p.Provider.(interface{ Client() *http.Client }); ok {
+		return c.Client()
+	}
+	return http.DefaultClient
+}
+
+type pkceSession struct {
+	goth.Session
+}
+
+func (s *pkceSession) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p, ok := provider.(*PKCEProvider)
+	if !ok {
+		return s.Session.Authorize(provider, params)
+	}
+	verifier := params.Get("code_verifier")
+	if verifier == "" {
+		return s.Session.Authorize(p.Provider, params)
+	}
+
+	token, err := p.config.Exchange(goth.ContextForClient(p.client()), params.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid() {
+		return "", errors.New("invalid token received from provider")
+	}
+
+	switch session := s.Session.(type) {
+	case *google.Session:
+		session.AccessToken = token.AccessToken
+		session.RefreshToken = token.RefreshToken
+		session.ExpiresAt = token.Expiry
+		if idToken, ok := token.Extra("id_token").(string); ok {
+			session.IDToken = idToken
+		}
+	case *gitlab.Session:
+		session.AccessToken = token.AccessToken
+		session.RefreshToken = token.RefreshToken
+		session.ExpiresAt = token.Expiry
+	case *microsoftonline.Session:
+		session.AccessToken = token.AccessToken
+		session.ExpiresAt = token.Expiry
+	default:
+		return "", errors.New("provider session does not support PKCE")
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+// SAML configures the SAML connections. They all share the SP key pair
+// from SAML_SP_CERT_FILE and SAML_SP_KEY_FILE.
+type SAML struct {
+	CertFile    string
+	KeyFile     string
+	Connections []SAMLConnection
+}
+
+// SAMLConnection is one entry of SAML_CONNECTIONS, read from
+// SAML_<NAME>_METADATA_FILE or SAML_<NAME>_METADATA_URL, the
+// SAML_<NAME>_DOMAINS the IdP may assert emails for and the optional
+// SAML_<NAME>_ATTR_* mapping.
+type SAMLConnection struct {
+	Name              string
+	MetadataFile      string
+	MetadataURL       string
+	Domains           []string
+	AllowIDPInitiated bool
+	AttrEmail         string
+	AttrUsername      string
+	AttrAvatarURL     string
+}
+
+func loadSAML(src *source) SAML {
+	cfg := SAML{
+		CertFile: src.str("SAML_SP_CERT_FILE", ""),
+		KeyFile:  src.str("SAML_SP_KEY_FILE", ""),
+	}
+	for _, name := range src.list("SAML_CONNECTIONS") {
+		name = strings.ToLower(name)
+		prefix := samlPrefix(name)
+		cfg.Connections = append(cfg.Connections, SAMLConnection{
+			Name:              name,
+			MetadataFile:      src.str(prefix+"METADATA_FILE", ""),
+			MetadataURL:       src.str(prefix+"METADATA_URL", ""),
+			Domains:           src.list(prefix + "DOMAINS"),
+			AllowIDPInitiated: src.boolean(prefix+"ALLOW_IDP_INITIATED", false),
+			AttrEmail:         src.str(prefix+"ATTR_EMAIL", ""),
+			AttrUsername:      src.str(prefix+"ATTR_USERNAME", ""),
+			AttrAvatarURL:     src.str(prefix+"ATTR_AVATAR_URL", ""),
+		})
+	}
+	return cfg
+}
+
+func (s SAML) validate(src *source) {
+	if len(s.Connections) == 0 {
+		return
+	}
+	if s.CertFile == "" || s.KeyFile == "" {
+		src.problem("SAML_SP_CERT_FILE and SAML_SP_KEY_FILE are required when SAML_CONNECTIONS is set")
+	}
+	for _, c := range s.Connections {
+		if c.MetadataFile == "" && c.MetadataURL == "" {
+			src.problem("%sMETADATA_FILE or %sMETADATA_URL is required", samlPrefix(c.Name), samlPrefix(c.Name))
+		}
+	}
+}
+
+func samlPrefix(name string) string {
+	return "SAML_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// InitSAML registers the connections of cfg.SAML.
+func InitSAML(cfg *Config) {
+	if len(cfg.SAML.Connections) == 0 {
+		return
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(cfg.SAML.CertFile, cfg.SAML.KeyFile)
+	if err != nil {
+		log.Fatal("Error loading SAML service provider key pair:", err)
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		log.Fatal("Error parsing SAML service provider certificate:", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		log.Fatal("SAML service provider key must be an RSA key")
+	}
+
+	for _, c := range cfg.SAML.Connections {
+		connection, err := newSAMLConnection(c, cfg.Server.BaseURL, key, certificate)
+		if err != nil {
+			log.Fatalf("Error configuring SAML connection %q: %v", c.Name, err)
+		}
+		utils.UseSAMLConnections(connection)
+	}
+}
+
+func newSAMLConnection(c SAMLConnection, baseURL string, key *rsa.PrivateKey, certificate *x509.Certificate) (*utils.SAMLConnection, error) {
+	idpMetadata, err := loadIDPMetadata(c.MetadataFile, c.MetadataURL)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataURL, err := url.Parse(baseURL + "/api/saml/" + c.Name + "/metadata")
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(baseURL + "/api/saml/" + c.Name + "/acs")
+	if err != nil {
+		return nil, err
+	}
+
+	return &utils.SAMLConnection{
+		Name: c.Name,
+		SP: &saml.ServiceProvider{
+			EntityID:          metadataURL.String(),
+			Key:               key,
+			Certificate:       certificate,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			IDPMetadata:       idpMetadata,
+			AllowIDPInitiated: c.AllowIDPInitiated,
+		},
+		Mapping: utils.SAMLAttributeMapping{
+			Email:     c.AttrEmail,
+			Username:  c.AttrUsername,
+			AvatarURL: c.AttrAvatarURL,
+		},
+		Domains: c.Domains,
+	}, nil
+}
+
+func loadIDPMetadata(file, metadataURL string) (*saml.EntityDescriptor, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.ParseMetadata(data)
+	}
+
+	if metadataURL == "" {
+		return nil, fmt.Errorf("metadata file or url is required")
+	}
+
+	parsed, err := url.Parse(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return samlsp.FetchMetadata(ctx, http.DefaultClient, *parsed)
+}
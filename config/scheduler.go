@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// Scheduler configures the background cleanup jobs. They run on one replica
+// at a time; SCHEDULER_ENABLED=false keeps a replica out of the election.
+type Scheduler struct {
+	Enabled        bool
+	PurgeInterval  time.Duration
+	PurgeRetention time.Duration
+}
+
+func loadScheduler(src *source) Scheduler {
+	return Scheduler{
+		Enabled:        src.boolean("SCHEDULER_ENABLED", true),
+		PurgeInterval:  src.duration("PURGE_INTERVAL", time.Hour),
+		PurgeRetention: src.duration("PURGE_RETENTION", 30*24*time.Hour),
+	}
+}
@@ -1,39 +1,153 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
+	goerror "errors"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func NewServer() *Config {
-	return &Config{
-		Port:           os.Getenv("PORT"),
-		AllowedOrigins: os.Getenv("ALLOWED_ORIGINS"),
-	}
+// Server configures the HTTP server and the URLs and cookies of browser
+// flows.
+type Server struct {
+	Port              int
+	AllowedOrigins    []string
+	BaseURL           string
+	FrontendURL       string
+	RedirectAllowlist []string
+	CookieDomain      string
+	SecureCookies     bool
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration
+
+	// TLSCertFile and TLSKeyFile serve HTTPS when both are set. With
+	// TLSReload the files are checked every TLSReloadInterval and a renewed
+	// certificate is used without a restart.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSReload         bool
+	TLSReloadInterval time.Duration
 }
 
-func (config *Config) InitServer(r *gin.Engine) {
-	if config.Port == "" {
-		config.Port = "8080"
+func loadServer(src *source, production bool) Server {
+	return Server{
+		Port:              src.integer("PORT", 8080),
+		AllowedOrigins:    src.list("ALLOWED_ORIGINS"),
+		BaseURL:           src.str("BASE_URL", ""),
+		FrontendURL:       src.str("FRONTEND_URL", "http://localhost:3000"),
+		RedirectAllowlist: src.list("REDIRECT_ALLOWLIST"),
+		CookieDomain:      src.str("DOMAIN", "localhost"),
+		SecureCookies:     production,
+
+		ReadTimeout:       src.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: src.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      src.duration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       src.duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    src.integer("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:   src.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		TLSCertFile:       src.str("TLS_CERT_FILE", ""),
+		TLSKeyFile:        src.str("TLS_KEY_FILE", ""),
+		TLSReload:         src.boolean("TLS_RELOAD", false),
+		TLSReloadInterval: src.duration("TLS_RELOAD_INTERVAL", time.Minute),
 	}
+}
 
-	if config.AllowedOrigins == "" || config.Port == "" {
-		log.Fatal("ALLOWED_ORIGINS or PORT is not set in .env file")
+func (s Server) validate(src *source) {
+	if s.Port < 1 || s.Port > 65535 {
+		src.problem("PORT must be between 1 and 65535, got %d", s.Port)
 	}
+	if len(s.AllowedOrigins) == 0 {
+		src.problem("ALLOWED_ORIGINS is required")
+	}
+	if s.MaxHeaderBytes < 1024 {
+		src.problem("HTTP_MAX_HEADER_BYTES must be at least 1024, got %d", s.MaxHeaderBytes)
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		src.problem("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if s.TLSReload && s.TLSCertFile == "" {
+		src.problem("TLS_RELOAD requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+}
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{config.AllowedOrigins},
+// CORS allows the browser origins of ALLOWED_ORIGINS. It has to be added
+// before the routes are registered.
+func (s Server) CORS() gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOrigins:     s.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
-	}))
+	})
+}
+
+// Serve runs the HTTP server until ctx is cancelled. It then stops accepting
+// connections and waits up to ShutdownTimeout for in-flight requests to
+// finish before returning.
+func (s Server) Serve(ctx context.Context, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.Port),
+		Handler:           handler,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+
+	if s.TLSCertFile != "" {
+		certs, err := newCertReloader(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		if s.TLSReload {
+			go certs.watch(ctx, s.TLSReloadInterval)
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+		}
+	}
 
-	r.Run(fmt.Sprintf(":%s", config.Port))
+	errs := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errs <- srv.ListenAndServeTLS("", "")
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+	log.Printf("Server is running on port %d", s.Port)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", s.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to drain connections: %v", err)
+	}
+	if err := <-errs; !goerror.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up by their environment variable name, first in the
+// environment and then in the config file. Values that do not parse are
+// recorded as problems and replaced by the fallback, so that every problem
+// can be reported at once.
+type source struct {
+	file     map[string]string
+	problems []string
+}
+
+// newSource reads the YAML or TOML config file at path, if any. Nested keys
+// are joined with underscores and upper-cased, so that
+//
+//	db:
+//	  host: localhost
+//
+// sets DB_HOST. Lists are joined with commas.
+func newSource(path string) (*source, error) {
+	src := &source{file: map[string]string{}}
+	if path == "" {
+		return src, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	flatten("", tree, src.file)
+	return src, nil
+}
+
+func flatten(prefix string, tree map[string]any, into map[string]string) {
+	for key, value := range tree {
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(name, v, into)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			into[name] = strings.Join(items, ",")
+		case nil:
+		default:
+			into[name] = fmt.Sprint(v)
+		}
+	}
+}
+
+func (s *source) lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+func (s *source) problem(format string, args ...any) {
+	s.problems = append(s.problems, fmt.Sprintf(format, args...))
+}
+
+func (s *source) str(key, fallback string) string {
+	if value := s.lookup(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (s *source) integer(key string, fallback int) int {
+	value := s.lookup(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		s.problem("%s must be an integer, got %q", key, value)
+		return fallback
+	}
+	return n
+}
+
+func (s *source) boolean(key string, fallback bool) bool {
+	value := s.lookup(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		s.problem("%s must be true or false, got %q", key, value)
+		return fallback
+	}
+	return b
+}
+
+func (s *source) duration(key string, fallback time.Duration) time.Duration {
+	value := s.lookup(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		s.problem("%s must be a positive duration such as 30s or 1h, got %q", key, value)
+		return fallback
+	}
+	return d
+}
+
+// list splits a comma separated value, dropping empty entries.
+func (s *source) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(s.lookup(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Error lists every problem found in the configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	problems := append([]string(nil), e.Problems...)
+	sort.Strings(problems)
+	return "invalid configuration:\n  - " + strings.Join(problems, "\n  - ")
+}
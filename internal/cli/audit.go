@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+func audit(ctx context.Context, svc service.Service, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: audit verify")
+	}
+
+	switch args[0] {
+	case "verify":
+		return verifyAudit(ctx, svc)
+	default:
+		return fmt.Errorf("unknown audit command %q", args[0])
+	}
+}
+
+// verifyAudit walks the audit hash chain and fails at the first event that
+// was modified, removed or inserted out of order.
+func verifyAudit(ctx context.Context, svc service.Service) error {
+	report, err := svc.Audit().VerifyChain(ctx)
+	if err != nil {
+		return err
+	}
+
+	if report.Legacy > 0 {
+		fmt.Printf("%d events predate the hash chain and were not verified\n", report.Legacy)
+	}
+	if report.BrokenAt != nil {
+		return fmt.Errorf("audit chain broken at event %d: %s (%d events verified before it)", *report.BrokenAt, report.Reason, report.Checked)
+	}
+
+	fmt.Printf("audit chain intact: %d events verified, last event %d\n", report.Checked, report.LastID)
+	return nil
+}
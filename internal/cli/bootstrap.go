@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// bootstrapAdmin grants the admin role to the first administrator, creating
+// the account when it does not exist yet.
+func bootstrapAdmin(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the first admin")
+	password := fs.String("password", os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"), "password used when the account has to be created")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	if err := svc.Roles().BootstrapAdmin(ctx, *email, *password); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now an admin\n", *email)
+	return nil
+}
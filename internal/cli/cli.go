@@ -0,0 +1,141 @@
+// Package cli provides the administrative subcommands of the server binary.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	goerror "errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/service"
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+// Usage lists the commands of the binary. serve is handled by main.
+const Usage = `usage: auth-go <command> [arguments]
+
+commands:
+  serve                                   start the HTTP server (the default)
+  migrate up [n] | down [n] | status      apply, revert or list schema migrations
+  migrate create [-dir path] <name>       write a new migration
+  bootstrap-admin -email <email>          make the first admin
+  user create -email <email> [-password-file <path>] [-username <name>] [-verified]
+  user disable -user <id|email> [-reason <reason>]
+  user verify -user <id|email>
+  user set-password -user <id|email> [-password-file <path>]
+  user grant-role -user <id|email> -role <role>
+  sessions revoke -user <id|email>
+  keys generate -account <id> [-expires-in <duration>] [-allow-ip <ip|cidr>,...]
+  keys rotate -account <id> -key <id> [-overlap-hours <hours>]
+  purge-expired [-retention <duration>]
+  audit verify                            check the audit log hash chain`
+
+// cliUserAgent is recorded as the user agent of audit events written by
+// commands.
+const cliUserAgent = "auth-go-cli"
+
+// Run executes the command in args. Commands go through the same services as
+// the HTTP API, so they are validated, audited and raise webhooks the same
+// way.
+func Run(ctx context.Context, db *sql.DB, svc service.Service, args []string) error {
+	ctx = utils.WithRequestMetadata(ctx, utils.RequestMetadata{UserAgent: cliUserAgent})
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = migrateCommand(ctx, db, args[1:])
+	case "bootstrap-admin":
+		err = bootstrapAdmin(ctx, svc, args[1:])
+	case "user":
+		err = user(ctx, svc, args[1:])
+	case "sessions":
+		err = sessions(ctx, svc, args[1:])
+	case "keys":
+		err = keys(ctx, svc, args[1:])
+	case "purge-expired":
+		err = purgeExpired(ctx, svc, args[1:])
+	case "audit":
+		err = audit(ctx, svc, args[1:])
+	case "help":
+		fmt.Println(Usage)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", args[0], Usage)
+	}
+	return describe(err)
+}
+
+// describe adds the cause of a service error to its message, which only
+// carries what is safe to show API clients.
+func describe(err error) error {
+	var appErr *errors.Error
+	if goerror.As(err, &appErr) && appErr.Err != nil {
+		return fmt.Errorf("%s: %v", appErr.Message, appErr.Err)
+	}
+	return err
+}
+
+// resolveUser returns the id of the user ref names, either by id or by email.
+func resolveUser(ctx context.Context, svc service.Service, ref string) (int, error) {
+	if ref == "" {
+		return 0, fmt.Errorf("-user is required")
+	}
+	if id, err := strconv.Atoi(ref); err == nil {
+		user, err := svc.User().GetUserByID(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+
+	user, err := svc.User().GetUserByEmail(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// stdin is where readPassword reads a password without -password-file.
+var stdin io.Reader = os.Stdin
+
+// readPassword returns the first line of the file at path, or of standard
+// input when path is empty or "-". Passwords are never taken as arguments,
+// which end up in the shell history and the process list.
+func readPassword(path string) (string, error) {
+	r := stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read the password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// validate checks req against its validate tags like the HTTP handlers do.
+func validate(req any) error {
+	errs := utils.ValidateStruct(req)
+	if errs == nil {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, message := range errs {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
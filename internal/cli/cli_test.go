@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// fakeService answers the user lookups and admin actions of the user
+// commands.
+type fakeService struct {
+	service.Service
+	users     map[int]models.User
+	created   []models.CreateUserRequest
+	passwords map[int]string
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{
+		users:     map[int]models.User{42: {ID: 42, Email: "jane@example.test"}},
+		passwords: map[int]string{},
+	}
+}
+
+func (s *fakeService) User() service.UserService   { return fakeUsers{fakeService: s} }
+func (s *fakeService) Admin() service.AdminService { return fakeAdmin{fakeService: s} }
+
+type fakeUsers struct {
+	service.UserService
+	*fakeService
+}
+
+func (s fakeUsers) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, errors.NotFound("user not found", nil)
+	}
+	return &user, nil
+}
+
+func (s fakeUsers) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, errors.NotFound("user not found", nil)
+}
+
+type fakeAdmin struct {
+	service.AdminService
+	*fakeService
+}
+
+func (s fakeAdmin) CreateUser(ctx context.Context, req models.CreateUserRequest) (int, error) {
+	s.created = append(s.created, req)
+	return 43, nil
+}
+
+func (s fakeAdmin) SetUserPassword(ctx context.Context, id int, password string) error {
+	s.passwords[id] = password
+	return nil
+}
+
+// withStdin makes readPassword read input instead of standard input.
+func withStdin(t *testing.T, input string) {
+	previous := stdin
+	stdin = strings.NewReader(input)
+	t.Cleanup(func() { stdin = previous })
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	appErr, ok := err.(*errors.Error)
+	if !ok || appErr.Code != code {
+		t.Fatalf("err = %v, want status %d", err, code)
+	}
+}
+
+func TestResolveUser(t *testing.T) {
+	svc := newFakeService()
+	ctx := context.Background()
+
+	for _, ref := range []string{"42", "jane@example.test"} {
+		id, err := resolveUser(ctx, svc, ref)
+		if err != nil {
+			t.Fatalf("%s: %v", ref, err)
+		}
+		if id != 42 {
+			t.Errorf("%s: id = %d, want 42", ref, id)
+		}
+	}
+
+	_, err := resolveUser(ctx, svc, "7")
+	wantStatus(t, err, http.StatusNotFound)
+	_, err = resolveUser(ctx, svc, "john@example.test")
+	wantStatus(t, err, http.StatusNotFound)
+	if _, err := resolveUser(ctx, svc, ""); err == nil || !strings.Contains(err.Error(), "-user is required") {
+		t.Errorf("err = %v, want -user is required", err)
+	}
+}
+
+func TestUserRejectsUnknownCommands(t *testing.T) {
+	svc := newFakeService()
+	ctx := context.Background()
+
+	if err := user(ctx, svc, nil); err == nil || !strings.HasPrefix(err.Error(), "usage:") {
+		t.Errorf("err = %v, want usage", err)
+	}
+	if err := user(ctx, svc, []string{"promote"}); err == nil || !strings.Contains(err.Error(), `"promote"`) {
+		t.Errorf("err = %v, want unknown user command", err)
+	}
+}
+
+func TestCreateUserReadsThePasswordFromStdin(t *testing.T) {
+	svc := newFakeService()
+	withStdin(t, "a-strong-password\n")
+
+	err := user(context.Background(), svc, []string{"create", "-email", "john@example.test", "-username", "john", "-verified"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.CreateUserRequest{Email: "john@example.test", Username: "john", Password: "a-strong-password", Verified: true}
+	if len(svc.created) != 1 || svc.created[0] != want {
+		t.Errorf("created = %+v, want %+v", svc.created, want)
+	}
+}
+
+func TestCreateUserValidatesTheRequest(t *testing.T) {
+	svc := newFakeService()
+	ctx := context.Background()
+
+	withStdin(t, "short\n")
+	if err := user(ctx, svc, []string{"create", "-email", "john@example.test"}); err == nil {
+		t.Error("created a user with a short password")
+	}
+	withStdin(t, "a-strong-password\n")
+	if err := user(ctx, svc, []string{"create", "-email", "john"}); err == nil {
+		t.Error("created a user with an invalid email")
+	}
+	if err := user(ctx, svc, []string{"create", "-email", "john@example.test", "-password", "a-strong-password"}); err == nil {
+		t.Error("accepted a password as an argument")
+	}
+	if len(svc.created) != 0 {
+		t.Errorf("created = %+v", svc.created)
+	}
+}
+
+func TestSetUserPasswordReadsThePasswordFile(t *testing.T) {
+	svc := newFakeService()
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("another-password\r\nignored\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := user(context.Background(), svc, []string{"set-password", "-user", "jane@example.test", "-password-file", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.passwords[42]; got != "another-password" {
+		t.Errorf("password = %q, want another-password", got)
+	}
+}
+
+func TestSetUserPasswordValidatesThePassword(t *testing.T) {
+	svc := newFakeService()
+	withStdin(t, "short")
+
+	err := user(context.Background(), svc, []string{"set-password", "-user", "42"})
+	if err == nil || !strings.Contains(err.Error(), "password must be at least 8") {
+		t.Errorf("err = %v, want a password validation error", err)
+	}
+	if len(svc.passwords) != 0 {
+		t.Errorf("passwords = %v", svc.passwords)
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// keys manages service account API keys. The plaintext key is printed once
+// and cannot be recovered.
+func keys(ctx context.Context, svc service.Service, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: keys generate | rotate")
+	}
+
+	switch args[0] {
+	case "generate":
+		return generateKey(ctx, svc, args[1:])
+	case "rotate":
+		return rotateKey(ctx, svc, args[1:])
+	default:
+		return fmt.Errorf("unknown keys command %q", args[0])
+	}
+}
+
+func generateKey(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	accountID := fs.Int("account", 0, "id of the service account")
+	expiresIn := fs.Duration("expires-in", 0, "lifetime of the key; it never expires when 0")
+	allowedIPs := fs.String("allow-ip", "", "comma separated addresses or CIDR ranges the key may be used from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *accountID <= 0 {
+		return fmt.Errorf("-account is required")
+	}
+
+	var req models.CreateAPIKeyRequest
+	if *expiresIn > 0 {
+		expiresAt := time.Now().Add(*expiresIn)
+		req.ExpiresAt = &expiresAt
+	}
+	if *allowedIPs != "" {
+		req.AllowedIPs = strings.Split(*allowedIPs, ",")
+	}
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	key, plaintext, err := svc.ServiceAccounts().CreateKeyForAccount(ctx, *accountID, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created key %d for service account %d:\n%s\n", key.ID, *accountID, plaintext)
+	return nil
+}
+
+func rotateKey(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	accountID := fs.Int("account", 0, "id of the service account")
+	keyID := fs.Int("key", 0, "id of the key to rotate")
+	overlapHours := fs.Int("overlap-hours", int(service.DefaultKeyRotationOverlap/time.Hour), "hours the old key keeps working")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *accountID <= 0 || *keyID <= 0 {
+		return fmt.Errorf("-account and -key are required")
+	}
+
+	req := models.RotateAPIKeyRequest{OverlapHours: overlapHours}
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	key, plaintext, err := svc.ServiceAccounts().RotateKeyForAccount(ctx, *accountID, *keyID, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("key %d replaces key %d, which stops working in %d hours:\n%s\n", key.ID, *keyID, *overlapHours, plaintext)
+	return nil
+}
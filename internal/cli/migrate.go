@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/migrate"
+)
+
+// migrationsDir is where migrate create writes new migrations, relative to
+// the repository root.
+const migrationsDir = "internal/migrate/migrations"
+
+func migrateCommand(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up [n] | down [n] | status | create <name>")
+	}
+
+	if args[0] == "create" {
+		return createMigration(args[1:])
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrationCount(args[1:], 0)
+		if err != nil {
+			return err
+		}
+		applied, err := migrator.Up(ctx, n)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		n, err := migrationCount(args[1:], 1)
+		if err != nil {
+			return err
+		}
+		reverted, err := migrator.Down(ctx, n)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no migrations to revert")
+		}
+		return err
+	case "status":
+		return migrationStatus(ctx, migrator)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// migrationCount parses the optional number of migrations to apply or revert.
+func migrationCount(args []string, fallback int) (int, error) {
+	if len(args) == 0 {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("number of migrations must be a positive integer")
+	}
+	return n, nil
+}
+
+func migrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if s.Unknown {
+			state += " (no migration file in this binary)"
+		}
+		fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
+
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := fs.String("dir", migrationsDir, "directory to write the migration to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: migrate create [-dir path] <name>")
+	}
+
+	up, down, err := migrate.Create(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %s\ncreated %s\n", up, down)
+	return nil
+}
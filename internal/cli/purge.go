@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+func purgeExpired(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("purge-expired", flag.ContinueOnError)
+	retention := fs.Duration("retention", service.DefaultPurgeRetention, "how long expired and revoked records are kept")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *retention < 0 {
+		return fmt.Errorf("-retention must not be negative")
+	}
+
+	report, err := svc.Maintenance().PurgeExpired(ctx, *retention)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("purged %d link token nonces, %d legacy links, %d token logs, %d invitations, %d job runs, %d emails, %d webhook events and %d webhook deliveries\n",
+		report.LinkNonces, report.LegacyLinks, report.TokenLogs, report.Invitations, report.JobRuns, report.Emails, report.WebhookEvents, report.WebhookDeliveries)
+	return nil
+}
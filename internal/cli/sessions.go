@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+func sessions(ctx context.Context, svc service.Service, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: sessions revoke -user <id|email>")
+	}
+
+	switch args[0] {
+	case "revoke":
+		return revokeSessions(ctx, svc, args[1:])
+	default:
+		return fmt.Errorf("unknown sessions command %q", args[0])
+	}
+}
+
+func revokeSessions(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	ref := fs.String("user", "", "id or email of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := resolveUser(ctx, svc, *ref)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := svc.Admin().RevokeSessions(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("revoked %d sessions of user %d\n", revoked, id)
+	return nil
+}
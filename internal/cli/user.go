@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+func user(ctx context.Context, svc service.Service, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: user create | disable | verify | set-password | grant-role")
+	}
+
+	switch args[0] {
+	case "create":
+		return createUser(ctx, svc, args[1:])
+	case "disable":
+		return disableUser(ctx, svc, args[1:])
+	case "verify":
+		return verifyUser(ctx, svc, args[1:])
+	case "set-password":
+		return setUserPassword(ctx, svc, args[1:])
+	case "grant-role":
+		return grantRole(ctx, svc, args[1:])
+	default:
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+}
+
+func createUser(ctx context.Context, svc service.Service, args []string) error {
+	var req models.CreateUserRequest
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	fs.StringVar(&req.Email, "email", "", "email of the new user")
+	fs.StringVar(&req.Username, "username", "", "username of the new user")
+	passwordFile := fs.String("password-file", "", "file holding the password of the new user (default standard input)")
+	fs.BoolVar(&req.Verified, "verified", false, "mark the email as verified instead of sending a verification email")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+	req.Password = password
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	id, err := svc.Admin().CreateUser(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %d (%s)\n", id, req.Email)
+	return nil
+}
+
+// disableUser suspends the account and revokes its sessions.
+func disableUser(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("user disable", flag.ContinueOnError)
+	ref := fs.String("user", "", "id or email of the user")
+	reason := fs.String("reason", "disabled by an operator", "reason shown to admins")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := resolveUser(ctx, svc, *ref)
+	if err != nil {
+		return err
+	}
+
+	req := models.UpdateUserStatusRequest{Status: models.UserStatusSuspended, Reason: *reason}
+	if err := validate(req); err != nil {
+		return err
+	}
+	if err := svc.Admin().SetUserStatus(ctx, 0, id, req); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %d is suspended\n", id)
+	return nil
+}
+
+func verifyUser(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("user verify", flag.ContinueOnError)
+	ref := fs.String("user", "", "id or email of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	id, err := resolveUser(ctx, svc, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Admin().VerifyUserEmail(ctx, id); err != nil {
+		return err
+	}
+
+	fmt.Printf("email of user %d is verified\n", id)
+	return nil
+}
+
+func setUserPassword(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("user set-password", flag.ContinueOnError)
+	ref := fs.String("user", "", "id or email of the user")
+	passwordFile := fs.String("password-file", "", "file holding the new password (default standard input)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+	req := models.SetPasswordRequest{Password: password}
+	if err := validate(req); err != nil {
+		return err
+	}
+	id, err := resolveUser(ctx, svc, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Admin().SetUserPassword(ctx, id, req.Password); err != nil {
+		return err
+	}
+
+	fmt.Printf("password of user %d was changed and their sessions revoked\n", id)
+	return nil
+}
+
+func grantRole(ctx context.Context, svc service.Service, args []string) error {
+	fs := flag.NewFlagSet("user grant-role", flag.ContinueOnError)
+	ref := fs.String("user", "", "id or email of the user")
+	role := fs.String("role", "", "name of the role")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *role == "" {
+		return fmt.Errorf("-role is required")
+	}
+	id, err := resolveUser(ctx, svc, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Roles().AssignRole(ctx, id, *role); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %d now has the %s role\n", id, *role)
+	return nil
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) GetRoles(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	roles, err := h.svc.Roles().GetAllRoles(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if roles == nil {
+		roles = []*models.Role{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Roles retrieved successfully", "roles": roles})
+}
+
+func (h *MainHandler) GetUserRoles(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	roles, err := h.svc.Roles().GetUserRoles(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User roles retrieved successfully", "roles": roles})
+}
+
+func (h *MainHandler) AssignUserRole(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	if err := h.svc.Roles().AssignRole(ctx, id, req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+func (h *MainHandler) RemoveUserRole(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	if err := h.svc.Roles().RemoveRole(ctx, id, c.Param("role")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
+
+func (h *MainHandler) SearchUsers(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	var filter models.UserFilter
+	if isValid := utils.BindQueryWithValidation(c, &filter); !isValid {
+		return
+	}
+
+	users, total, err := h.svc.Admin().SearchUsers(ctx, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if users == nil {
+		users = []*models.User{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Users retrieved successfully", "users": users, "total": total})
+}
+
+func (h *MainHandler) GetUserDetails(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	details, err := h.svc.Admin().GetUserDetails(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User retrieved successfully", "user": details.User, "roles": details.Roles, "identities": details.Identities, "sessions": details.Sessions, "impersonations": details.Impersonations})
+}
+
+func (h *MainHandler) ForcePasswordReset(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	if err := h.svc.Admin().ForcePasswordReset(ctx, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset forced successfully"})
+}
+
+func (h *MainHandler) AdminVerifyEmail(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	if err := h.svc.Admin().VerifyUserEmail(ctx, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *MainHandler) UpdateUserStatus(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.UpdateUserStatusRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	if err := h.svc.Admin().SetUserStatus(ctx, currentUser.ID, id, req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated successfully"})
+}
+
+func (h *MainHandler) RevokeUserSessions(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	revoked, err := h.svc.Admin().RevokeSessions(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+func (h *MainHandler) AdminDeleteUser(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	hard := c.Query("hard") == "true"
+	if err := h.svc.Admin().DeleteUser(ctx, currentUser.ID, id, hard); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
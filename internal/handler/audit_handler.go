@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var auditCSVHeader = []string{"id", "created_at", "action", "actor_id", "target_type", "target_id", "ip_address", "user_agent", "request_id", "metadata", "prev_hash", "hash"}
+
+func (h *MainHandler) SearchAuditEvents(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	var filter models.AuditFilter
+	if isValid := utils.BindQueryWithValidation(c, &filter); !isValid {
+		return
+	}
+
+	events, next, err := h.svc.Audit().SearchEvents(ctx, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if events == nil {
+		events = []*models.AuditEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Audit events retrieved successfully", "events": events, "next_cursor": next})
+}
+
+// ExportAuditEvents streams every matching event as NDJSON or CSV. Exports
+// can be large, so it uses the request context without the usual timeout and
+// lifts the server's write deadline for this response.
+func (h *MainHandler) ExportAuditEvents(c *gin.Context) {
+	var filter models.AuditFilter
+	if isValid := utils.BindQueryWithValidation(c, &filter); !isValid {
+		return
+	}
+
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		c.Error(errors.InternalServerError("Failed to start audit export", err))
+		return
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		c.Error(errors.BadRequest("format must be ndjson or csv", nil))
+		return
+	}
+
+	var (
+		started bool
+		encoder *json.Encoder
+		writer  *csv.Writer
+	)
+	start := func() error {
+		started = true
+		filename := "audit-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			writer = csv.NewWriter(c.Writer)
+			return writer.Write(auditCSVHeader)
+		}
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder = json.NewEncoder(c.Writer)
+		return nil
+	}
+
+	err := h.svc.Audit().ExportEvents(c.Request.Context(), filter, func(event *models.AuditEvent) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if writer != nil {
+			if err := writer.Write(auditEventRecord(event)); err != nil {
+				return err
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+		return encoder.Encode(event)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if writer != nil {
+		writer.Flush()
+	}
+
+	if err != nil {
+		if !started {
+			c.Error(err)
+			return
+		}
+		// The response is already under way, all we can do is cut it short.
+		log.Println("audit export failed:", err)
+	}
+}
+
+func (h *MainHandler) GetUserActivity(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	activity, err := h.svc.Audit().GetUserActivity(ctx, currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Activity retrieved successfully", "activity": activity})
+}
+
+func auditEventRecord(event *models.AuditEvent) []string {
+	actorID := ""
+	if event.ActorID != nil {
+		actorID = strconv.Itoa(*event.ActorID)
+	}
+	return []string{
+		strconv.FormatInt(event.ID, 10),
+		event.CreatedAt.Format(time.RFC3339Nano),
+		event.Action,
+		actorID,
+		event.TargetType,
+		event.TargetID,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestID,
+		string(event.Metadata),
+		event.PrevHash,
+		event.Hash,
+	}
+}
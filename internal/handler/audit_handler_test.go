@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// slowAuditService exports events with a pause before each one, like a large
+// export reading batch after batch.
+type slowAuditService struct {
+	service.AuditService
+	events int
+	pause  time.Duration
+}
+
+func (s slowAuditService) ExportEvents(ctx context.Context, filter models.AuditFilter, fn func(event *models.AuditEvent) error) error {
+	for i := 1; i <= s.events; i++ {
+		time.Sleep(s.pause)
+		if err := fn(&models.AuditEvent{ID: int64(i), Action: "user.login"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportAuditEventsOutlivesWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewMainHandler(&fakeService{audit: slowAuditService{events: 5, pause: 50 * time.Millisecond}}, config.Server{})
+	r := gin.New()
+	r.GET("/api/admin/audit/export", h.ExportAuditEvents)
+
+	server := httptest.NewUnstartedServer(r)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/admin/audit/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("export cut off after %d events: %v", lines, err)
+	}
+	if lines != 5 {
+		t.Errorf("exported %d events, want 5", lines)
+	}
+}
@@ -3,7 +3,6 @@ package handler
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/Jonathan0823/auth-go/internal/errors"
 	"github.com/Jonathan0823/auth-go/internal/models"
@@ -11,8 +10,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var secure = os.Getenv("ENVIRONMENT") == "production"
-
 func (h *MainHandler) Register(c *gin.Context) {
 	ctx, cancel := utils.CtxWithTimeOut(c)
 	defer cancel()
@@ -24,6 +21,7 @@ func (h *MainHandler) Register(c *gin.Context) {
 	var user models.User
 	user.Email = req.Email
 	user.Password = req.Password
+	user.Locale = utils.PreferredLocale(c.GetHeader("Accept-Language"))
 
 	if err := h.svc.Auth().Register(ctx, user); err != nil {
 		c.Error(err)
@@ -53,13 +51,7 @@ func (h *MainHandler) Login(c *gin.Context) {
 		return
 	}
 
-	domain := os.Getenv("DOMAIN")
-	if domain == "" {
-		domain = "localhost"
-	}
-
-	c.SetCookie("access_token", accessToken, 7*24*3600, "/", domain, secure, false)
-	c.SetCookie("refresh_token", refreshToken, 7*24*3600, "/", domain, secure, true)
+	h.setAuthCookies(c, accessToken, refreshToken)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User logged in successfully"})
 }
@@ -76,16 +68,16 @@ func (h *MainHandler) Logout(c *gin.Context) {
 	claims, err := utils.ValidateJWT(refreshToken, "refresh")
 	if err != nil {
 		c.Error(errors.Unauthorized("Invalid refresh token", err))
+		return
 	}
 
-	oldJTI := claims["jti"].(string)
-	if err := h.svc.Auth().InvalidateJWTTokens(ctx, oldJTI, ""); err != nil {
+	oldJTI, _ := claims["jti"].(string)
+	if err := h.svc.Auth().InvalidateJWTTokens(ctx, oldJTI); err != nil {
 		c.Error(err)
 		return
 	}
-	domain := os.Getenv("DOMAIN")
-	c.SetCookie("access_token", "", -1, "/", domain, secure, false)
-	c.SetCookie("refresh_token", "", -1, "/", domain, secure, true)
+	c.SetCookie("access_token", "", -1, "/", h.cookieDomain, h.secureCookies, false)
+	c.SetCookie("refresh_token", "", -1, "/", h.cookieDomain, h.secureCookies, true)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User logged out successfully"})
 }
@@ -105,22 +97,24 @@ func (h *MainHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	domain := os.Getenv("DOMAIN")
-	if domain == "" {
-		domain = "localhost"
-	}
-
-	c.SetCookie("access_token", newAccessToken, 7*24*3600, "/", domain, secure, false)
-	c.SetCookie("refresh_token", newRefreshToken, 7*24*3600, "/", domain, secure, true)
+	h.setAuthCookies(c, newAccessToken, newRefreshToken)
 	c.JSON(http.StatusOK, gin.H{"message": "Access token refreshed successfully"})
 }
 
 func (h *MainHandler) VerifyEmail(c *gin.Context) {
 	ctx, cancel := utils.CtxWithTimeOut(c)
 	defer cancel()
-	id := c.Query("id")
+	token := c.Query("token")
+	if token == "" {
+		// Links sent before links were signed carry the id instead.
+		token = c.Query("id")
+	}
+	if token == "" {
+		c.Error(errors.BadRequest("Token is required", nil))
+		return
+	}
 
-	if err := h.svc.Auth().VerifyEmail(ctx, id); err != nil {
+	if err := h.svc.Auth().VerifyEmail(ctx, token); err != nil {
 		c.Error(err)
 		return
 	}
@@ -172,9 +166,19 @@ func (h *MainHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.Auth().ResetPassword(ctx, req.ID, req.Password); err != nil {
+	token := req.Token
+	if token == "" {
+		token = req.ID
+	}
+	if err := h.svc.Auth().ResetPassword(ctx, token, req.Password); err != nil {
 		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
+
+func (h *MainHandler) setAuthCookies(c *gin.Context, accessToken, refreshToken string) {
+	c.SetCookie("access_token", accessToken, 7*24*3600, "/", h.cookieDomain, h.secureCookies, false)
+	c.SetCookie("refresh_token", refreshToken, 7*24*3600, "/", h.cookieDomain, h.secureCookies, true)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) GetEmails(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+
+	var filter models.EmailFilter
+	if isValid := utils.BindQueryWithValidation(c, &filter); !isValid {
+		return
+	}
+
+	emails, err := h.svc.Emails().GetEmails(ctx, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if emails == nil {
+		emails = []*models.Email{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emails retrieved successfully", "emails": emails})
+}
+
+func (h *MainHandler) RetryEmail(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid email ID", nil))
+		return
+	}
+
+	if err := h.svc.Emails().Retry(ctx, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Email queued successfully"})
+}
+
+// PreviewEmailTemplate renders a template with sample data. format=html or
+// format=text returns that part alone so it can be opened in a browser.
+func (h *MainHandler) PreviewEmailTemplate(c *gin.Context) {
+	msg, err := h.svc.Emails().Preview(c.Param("name"), c.Query("locale"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Email template rendered successfully", "subject": msg.Subject, "html": msg.HTML, "text": msg.Text})
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StartImpersonation replaces the access token cookie with an impersonation
+// token. The admin's refresh token cookie is left alone, so refreshing the
+// session after it ends signs the admin back in.
+func (h *MainHandler) StartImpersonation(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid user ID", nil))
+		return
+	}
+
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.ImpersonateRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	token, session, err := h.svc.Impersonation().StartImpersonation(ctx, currentUser, id, req.Reason, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetCookie("access_token", token, int(utils.ImpersonationTTL.Seconds()), "/", h.cookieDomain, h.secureCookies, false)
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation started successfully", "access_token": token, "session": session})
+}
+
+func (h *MainHandler) StopImpersonation(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	if err := h.svc.Impersonation().StopImpersonation(ctx, currentUser, utils.GetTokenID(c), c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetCookie("access_token", "", -1, "/", h.cookieDomain, h.secureCookies, false)
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation stopped successfully"})
+}
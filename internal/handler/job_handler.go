@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) GetJobs(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+
+	jobs, err := h.svc.Maintenance().GetJobSummaries(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []*models.JobSummary{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Jobs retrieved successfully", "jobs": jobs})
+}
+
+func (h *MainHandler) GetJobRuns(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+
+	runs, err := h.svc.Maintenance().GetJobRuns(ctx, c.Param("name"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if runs == nil {
+		runs = []*models.JobRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job runs retrieved successfully", "runs": runs})
+}
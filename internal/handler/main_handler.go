@@ -1,13 +1,20 @@
 package handler
 
-import "github.com/Jonathan0823/auth-go/internal/service"
+import (
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
 
 type MainHandler struct {
-	svc service.Service
+	svc           service.Service
+	cookieDomain  string
+	secureCookies bool
 }
 
-func NewMainHandler(svc service.Service) *MainHandler {
+func NewMainHandler(svc service.Service, server config.Server) *MainHandler {
 	return &MainHandler{
-		svc: svc,
+		svc:           svc,
+		cookieDomain:  server.CookieDomain,
+		secureCookies: server.SecureCookies,
 	}
 }
@@ -1,25 +1,65 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/Jonathan0823/auth-go/config"
 	"github.com/Jonathan0823/auth-go/internal/errors"
 	"github.com/Jonathan0823/auth-go/internal/models"
 	"github.com/Jonathan0823/auth-go/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/openidConnect"
 )
 
+const (
+	oauthFlowSessionName = "oauth_flow"
+	oauthFlowTTL         = 10 * time.Minute
+)
+
+// oauthFlow is the browser-bound half of an OAuth login. Each provider gets its
+// own session so parallel logins in one browser don't overwrite each other,
+// and gothic's session is avoided because it is rewritten on every store.
+type oauthFlow struct {
+	Nonce      string
+	Provider   string
+	Verifier   string
+	LinkUserID int
+}
+
 func (h *MainHandler) OAuthLogin(c *gin.Context) {
-	gothic.BeginAuthHandler(c.Writer, c.Request)
+	beginOAuth(c, 0)
 }
 
 func (h *MainHandler) OAuthCallback(c *gin.Context) {
 	ctx, cancel := utils.CtxWithTimeOut(c)
 	defer cancel()
+	flow, hasFlow := popOAuthFlow(c)
+	state, err := utils.VerifyOAuthState(c.Request.FormValue("state"))
+	redirectURL := utils.ResolveRedirectURL(state.ReturnTo)
+	if err != nil || !hasFlow || !flow.matches(state, c.Param("provider")) {
+		oauthRedirectError(c, redirectURL, "invalid_state", errors.Unauthorized("OAuth state mismatch", err))
+		return
+	}
+
+	if providerErr := c.Request.FormValue("error"); providerErr != "" {
+		oauthRedirectError(c, redirectURL, "access_denied", errors.Unauthorized(providerErr, nil))
+		return
+	}
+
+	if flow.Verifier != "" {
+		query := c.Request.URL.Query()
+		query.Set("code_verifier", flow.Verifier)
+		c.Request.URL.RawQuery = query.Encode()
+	}
+
 	user, err := gothic.CompleteUserAuth(c.Writer, c.Request)
 	if err != nil {
-		c.Error(errors.Unauthorized("OAuth authentication failed", err))
+		oauthRedirectError(c, redirectURL, "oauth_failed", err)
 		return
 	}
 
@@ -31,14 +71,254 @@ func (h *MainHandler) OAuthCallback(c *gin.Context) {
 		AvatarURL: user.AvatarURL,
 	}
 
-	userData, err := h.svc.OAuth().OAuthLogin(ctx, data)
+	if flow.LinkUserID != 0 {
+		currentUser, err := currentUserFromCookie(c)
+		if err != nil || currentUser.ID != flow.LinkUserID {
+			oauthRedirectError(c, redirectURL, "unauthorized", errors.Unauthorized("User is not authenticated", err))
+			return
+		}
+
+		if err := h.svc.OAuth().LinkIdentity(ctx, currentUser.ID, data); err != nil {
+			oauthRedirectError(c, redirectURL, oauthErrorCode(err), err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, utils.AppendQuery(redirectURL, "linked", user.Provider))
+		return
+	}
+
+	userData, err := h.svc.OAuth().OAuthLogin(ctx, data, oauthEmailVerified(user))
+	if err != nil {
+		oauthRedirectError(c, redirectURL, oauthErrorCode(err), err)
+		return
+	}
+
+	userData.IPAddress = c.ClientIP()
+	userData.UserAgent = c.GetHeader("User-Agent")
+	accessToken, refreshToken, err := h.svc.Auth().IssueTokens(ctx, *userData)
+	if err != nil {
+		oauthRedirectError(c, redirectURL, oauthErrorCode(err), err)
+		return
+	}
+
+	h.setAuthCookies(c, accessToken, refreshToken)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+func (h *MainHandler) LinkOAuth(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	beginOAuth(c, user.ID)
+}
+
+func (h *MainHandler) GetIdentities(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	identities, err := h.svc.OAuth().GetIdentities(ctx, user.ID)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User logged in successfully",
-		"user":    userData,
+	if identities == nil {
+		identities = []*models.UserIdentity{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identities retrieved successfully", "identities": identities})
+}
+
+func (h *MainHandler) UnlinkOAuth(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	if err := h.svc.OAuth().UnlinkIdentity(ctx, user.ID, c.Param("provider")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Provider unlinked successfully"})
+}
+
+// beginOAuth redirects to the provider. The signed state carries an
+// allow-listed return_to, and its nonce plus the PKCE verifier are kept in a
+// short-lived session so the callback only succeeds in the same browser.
+func beginOAuth(c *gin.Context, linkUserID int) {
+	providerName := c.Param("provider")
+	provider, err := goth.GetProvider(providerName)
+	if err != nil {
+		c.Error(errors.NotFound("OAuth provider not found", err))
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if !utils.IsAllowedRedirect(returnTo) {
+		returnTo = ""
+	}
+
+	flow := oauthFlow{Provider: providerName, LinkUserID: linkUserID}
+	if flow.Nonce, err = utils.RandomToken(32); err != nil {
+		c.Error(errors.InternalServerError("failed to start OAuth login", err))
+		return
+	}
+	if supportsPKCE(provider) {
+		if flow.Verifier, err = utils.RandomToken(48); err != nil {
+			c.Error(errors.InternalServerError("failed to start OAuth login", err))
+			return
+		}
+	}
+
+	state, err := utils.SignOAuthState(utils.OAuthState{
+		Nonce:     flow.Nonce,
+		Provider:  providerName,
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(oauthFlowTTL).Unix(),
 	})
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to start OAuth login", err))
+		return
+	}
+
+	if err := saveOAuthFlow(c, flow); err != nil {
+		c.Error(errors.InternalServerError("failed to start OAuth login", err))
+		return
+	}
+
+	query := c.Request.URL.Query()
+	query.Set("state", state)
+	c.Request.URL.RawQuery = query.Encode()
+
+	authURL, err := gothic.GetAuthURL(c.Writer, c.Request)
+	if err != nil {
+		c.Error(errors.BadRequest("failed to start OAuth login", err))
+		return
+	}
+
+	if flow.Verifier != "" {
+		authURL = utils.AppendQuery(authURL, "code_challenge", utils.PKCEChallenge(flow.Verifier))
+		authURL = utils.AppendQuery(authURL, "code_challenge_method", "S256")
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// oauthRedirectError sends the browser back to the frontend with an error
+// code instead of rendering JSON on the provider's redirect.
+func oauthRedirectError(c *gin.Context, redirectURL, code string, err error) {
+	log.Println("OAuth callback failed:", err)
+	c.Redirect(http.StatusFound, utils.AppendQuery(redirectURL, "error", code))
+}
+
+func oauthErrorCode(err error) string {
+	appErr, ok := err.(*errors.Error)
+	if !ok {
+		return "server_error"
+	}
+
+	switch appErr.Code {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusConflict:
+		return "account_conflict"
+	default:
+		return "server_error"
+	}
+}
+
+func saveOAuthFlow(c *gin.Context, flow oauthFlow) error {
+	session, _ := gothic.Store.New(c.Request, oauthFlowSessionName+"_"+flow.Provider)
+	session.Values["nonce"] = flow.Nonce
+	session.Values["provider"] = flow.Provider
+	session.Values["verifier"] = flow.Verifier
+	session.Values["link_user_id"] = flow.LinkUserID
+	session.Options.MaxAge = int(oauthFlowTTL.Seconds())
+	return session.Save(c.Request, c.Writer)
+}
+
+// popOAuthFlow reads and clears the pending flow so a state can only be
+// redeemed once.
+func popOAuthFlow(c *gin.Context) (oauthFlow, bool) {
+	session, err := gothic.Store.Get(c.Request, oauthFlowSessionName+"_"+c.Param("provider"))
+	if err != nil || session.IsNew {
+		return oauthFlow{}, false
+	}
+
+	var flow oauthFlow
+	flow.Nonce, _ = session.Values["nonce"].(string)
+	flow.Provider, _ = session.Values["provider"].(string)
+	flow.Verifier, _ = session.Values["verifier"].(string)
+	flow.LinkUserID, _ = session.Values["link_user_id"].(int)
+
+	session.Options.MaxAge = -1
+	session.Save(c.Request, c.Writer)
+	return flow, flow.Nonce != ""
+}
+
+func (f oauthFlow) matches(state utils.OAuthState, provider string) bool {
+	return subtle.ConstantTimeCompare([]byte(f.Nonce), []byte(state.Nonce)) == 1 &&
+		f.Provider == state.Provider &&
+		state.Provider == provider
+}
+
+// supportsPKCE reports whether the provider forwards a code_verifier to the
+// token endpoint.
+func supportsPKCE(provider goth.Provider) bool {
+	switch provider.(type) {
+	case *openidConnect.Provider, *config.PKCEProvider:
+		return true
+	}
+	return false
+}
+
+// currentUserFromCookie authenticates routes that cannot sit behind
+// AuthMiddleware, such as the provider callback.
+func currentUserFromCookie(c *gin.Context) (models.User, error) {
+	token, err := c.Cookie("access_token")
+	if err != nil || token == "" {
+		return models.User{}, err
+	}
+
+	claims, err := utils.ValidateJWT(token, "access")
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.Set("user", claims)
+	return utils.GetUser(c)
+}
+
+// oauthEmailVerified reports whether the provider vouches for the email
+// address. GitHub only hands out verified primary emails.
+func oauthEmailVerified(user goth.User) bool {
+	if user.Provider == "github" {
+		return user.Email != ""
+	}
+
+	for _, key := range []string{"email_verified", "verified_email"} {
+		switch v := user.RawData[key].(type) {
+		case bool:
+			return v
+		case string:
+			return v == "true"
+		}
+	}
+	return false
 }
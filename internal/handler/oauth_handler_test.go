@@ -0,0 +1,434 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/middleware"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+const testFrontendURL = "http://frontend.test"
+
+// stubGrant is what the stub provider issued an authorization code for.
+type stubGrant struct {
+	userID        string
+	email         string
+	codeChallenge string
+}
+
+// stubProvider plays GitHub, Google and an OIDC provider on one server. The
+// test acts as the browser and asks it for codes directly instead of going
+// through a login page.
+type stubProvider struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	grants map[string]stubGrant
+	tokens map[string]stubGrant
+	next   int
+
+	// verifierMismatches counts token requests whose code_verifier did not
+	// match the challenge of the code.
+	verifierMismatches int
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	p := &stubProvider{grants: map[string]stubGrant{}, tokens: map[string]stubGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/oidc/authorize",
+			"token_endpoint":         p.server.URL + "/oidc/token",
+		})
+	})
+	// GitHub, Google and OIDC token endpoints.
+	for _, path := range []string{"/login/oauth/access_token", "/token", "/oidc/token"} {
+		mux.HandleFunc(path, p.token)
+	}
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		grant, ok := p.bearer(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if !ok {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(grant.userID, "gh-")
+		writeJSON(w, map[string]any{"id": json.Number(id), "login": grant.userID, "email": grant.email})
+	})
+	mux.HandleFunc("/oauth2/v2/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		grant, ok := p.bearer(r.URL.Query().Get("access_token"))
+		if !ok {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{"id": grant.userID, "email": grant.email, "name": grant.userID, "verified_email": true})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize issues a code for a login, bound to the PKCE challenge of the
+// authorization URL when it carried one.
+func (p *stubProvider) authorize(authURL, userID, email string) string {
+	u, _ := url.Parse(authURL)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	code := fmt.Sprintf("code-%d", p.next)
+	p.grants[code] = stubGrant{userID: userID, email: email, codeChallenge: u.Query().Get("code_challenge")}
+	return code
+}
+
+func (p *stubProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.Form.Get("code")]
+	delete(p.grants, r.Form.Get("code"))
+	p.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]any{"error": "invalid_grant"})
+		return
+	}
+	if grant.codeChallenge != "" {
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge {
+			p.mu.Lock()
+			p.verifierMismatches++
+			p.mu.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]any{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+			return
+		}
+	}
+
+	accessToken := "at-" + grant.userID
+	p.mu.Lock()
+	p.tokens[accessToken] = grant
+	p.mu.Unlock()
+
+	response := map[string]any{"access_token": accessToken, "token_type": "bearer", "expires_in": 3600}
+	if r.URL.Path == "/oidc/token" {
+		response["id_token"] = unsignedJWT(map[string]any{
+			"iss":            p.server.URL,
+			"aud":            "oidc-client",
+			"sub":            grant.userID,
+			"email":          grant.email,
+			"email_verified": true,
+			"exp":            4102444800,
+		})
+	}
+	writeJSON(w, response)
+}
+
+func (p *stubProvider) bearer(token string) (stubGrant, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	grant, ok := p.tokens[token]
+	return grant, ok
+}
+
+// RoundTrip sends the requests GitHub and Google providers make to their
+// fixed hosts to the stub instead.
+func (p *stubProvider) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(p.server.URL)
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// unsignedJWT is enough for goth, which reads the id_token claims without
+// checking the signature because it came straight from the token endpoint.
+func unsignedJWT(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "none", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// fakeService records OAuth logins and issues tokens named after the user,
+// so a test can tell which identity a callback resolved to.
+type fakeService struct {
+	service.Service
+	oauth *fakeOAuthService
+	audit service.AuditService
+}
+
+func (s *fakeService) OAuth() service.OAuthService { return s.oauth }
+func (s *fakeService) Auth() service.AuthService   { return fakeAuthService{} }
+func (s *fakeService) Audit() service.AuditService { return s.audit }
+
+type fakeOAuthService struct {
+	service.OAuthService
+
+	mu       sync.Mutex
+	logins   []models.User
+	verified []bool
+}
+
+func (s *fakeOAuthService) OAuthLogin(ctx context.Context, user models.User, emailVerified bool) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins = append(s.logins, user)
+	s.verified = append(s.verified, emailVerified)
+	return &models.User{ID: len(s.logins), Email: user.Email, Username: user.Provider + "-" + user.OAuthID}, nil
+}
+
+type fakeAuthService struct {
+	service.AuthService
+}
+
+func (fakeAuthService) IssueTokens(ctx context.Context, user models.User) (string, string, error) {
+	return "access-" + user.Username, "refresh-" + user.Username, nil
+}
+
+// setupOAuth configures github, google and an oidc provider against a stub
+// and returns a router serving the OAuth routes.
+func setupOAuth(t *testing.T) (*gin.Engine, *stubProvider, *fakeOAuthService) {
+	gin.SetMode(gin.TestMode)
+	stub := newStubProvider(t)
+
+	utils.UseSecrets(utils.Secrets{JWTAccess: "access", JWTRefresh: "refresh", Session: "session-secret"})
+	utils.UseRedirects(testFrontendURL, nil)
+	config.InitOAuth(&config.Config{
+		Server:  config.Server{BaseURL: "http://api.test"},
+		Secrets: config.Secrets{Session: "session-secret"},
+		OAuth: []config.OAuthProvider{
+			{Name: "github", Type: "github", ClientID: "github-client", ClientSecret: "secret"},
+			{Name: "google", Type: "google", ClientID: "google-client", ClientSecret: "secret"},
+			{Name: "oidc", Type: "oidc", ClientID: "oidc-client", ClientSecret: "secret", DiscoveryURL: stub.server.URL + "/.well-known/openid-configuration"},
+		},
+	})
+
+	client := &http.Client{Transport: stub}
+	for _, name := range []string{"github", "google", "oidc"} {
+		provider, err := goth.GetProvider(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, ok := provider.(*config.PKCEProvider); ok {
+			provider = p.Provider
+		}
+		switch p := provider.(type) {
+		case *github.Provider:
+			p.HTTPClient = client
+		case *google.Provider:
+			p.HTTPClient = client
+		case *openidConnect.Provider:
+			p.HTTPClient = stub.server.Client()
+		}
+	}
+
+	oauth := &fakeOAuthService{}
+	h := NewMainHandler(&fakeService{oauth: oauth}, config.Server{CookieDomain: "api.test"})
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	provider := r.Group("/api/oauth/:provider", middleware.OAuthMiddleware())
+	provider.GET("/", h.OAuthLogin)
+	provider.GET("/callback", h.OAuthCallback)
+	return r, stub, oauth
+}
+
+// oauthBrowser holds the cookies of one browser across the round trip.
+type oauthBrowser struct {
+	cookies []*http.Cookie
+}
+
+func (b *oauthBrowser) do(r *gin.Engine, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range b.cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	b.cookies = append(b.cookies, w.Result().Cookies()...)
+	return w
+}
+
+// begin starts a login and returns the provider authorization URL.
+func (b *oauthBrowser) begin(r *gin.Engine, provider string) (*url.URL, error) {
+	w := b.do(r, "/api/oauth/"+provider+"/")
+	if w.Code != http.StatusTemporaryRedirect {
+		return nil, fmt.Errorf("begin %s: status %d, body %s", provider, w.Code, w.Body)
+	}
+	return url.Parse(w.Header().Get("Location"))
+}
+
+func (b *oauthBrowser) mustBegin(t *testing.T, r *gin.Engine, provider string) *url.URL {
+	t.Helper()
+	authURL, err := b.begin(r, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL
+}
+
+func (b *oauthBrowser) callback(r *gin.Engine, provider, code, state string) *httptest.ResponseRecorder {
+	return b.do(r, "/api/oauth/"+provider+"/callback?"+url.Values{"code": {code}, "state": {state}}.Encode())
+}
+
+func cookieValue(w *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func TestOAuthParallelFlows(t *testing.T) {
+	r, stub, oauth := setupOAuth(t)
+
+	const flowsPerProvider = 10
+	providers := []string{"github", "google", "oidc"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, flowsPerProvider*len(providers))
+	for _, provider := range providers {
+		for i := range flowsPerProvider {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				userID := fmt.Sprintf("%d", 1000+i)
+				if provider == "github" {
+					userID = "gh-" + userID
+				}
+				browser := &oauthBrowser{}
+				authURL, err := browser.begin(r, provider)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// GitHub does not support PKCE, the others must use it.
+				if hasChallenge := authURL.Query().Get("code_challenge") != ""; hasChallenge != (provider != "github") {
+					errs <- fmt.Errorf("%s: authorization URL has PKCE challenge %t", provider, hasChallenge)
+					return
+				}
+
+				code := stub.authorize(authURL.String(), userID, userID+"@example.com")
+				w := browser.callback(r, provider, code, authURL.Query().Get("state"))
+
+				if w.Code != http.StatusFound || w.Header().Get("Location") != testFrontendURL {
+					errs <- fmt.Errorf("%s %s: status %d, location %q", provider, userID, w.Code, w.Header().Get("Location"))
+					return
+				}
+				externalID := strings.TrimPrefix(userID, "gh-")
+				if got, want := cookieValue(w, "access_token"), "access-"+provider+"-"+externalID; got != want {
+					errs <- fmt.Errorf("%s %s: signed in as %q, want %q", provider, userID, got, want)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(oauth.logins) != flowsPerProvider*len(providers) {
+		t.Errorf("got %d logins, want %d", len(oauth.logins), flowsPerProvider*len(providers))
+	}
+}
+
+func TestOAuthCallbackRejectsMismatchedState(t *testing.T) {
+	r, stub, oauth := setupOAuth(t)
+
+	victim := &oauthBrowser{}
+	victimURL := victim.mustBegin(t, r, "github")
+	attacker := &oauthBrowser{}
+	attackerURL := attacker.mustBegin(t, r, "github")
+
+	tests := []struct {
+		name     string
+		provider string
+		state    string
+	}{
+		{"state of another browser", "github", attackerURL.Query().Get("state")},
+		{"tampered state", "github", victimURL.Query().Get("state") + "x"},
+		{"missing state", "github", ""},
+		{"state for another provider", "google", victimURL.Query().Get("state")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := stub.authorize(attackerURL.String(), "gh-1", "attacker@example.com")
+			browser := &oauthBrowser{cookies: victim.cookies}
+			w := browser.callback(r, tt.provider, code, tt.state)
+
+			if want := testFrontendURL + "?error=invalid_state"; w.Header().Get("Location") != want {
+				t.Errorf("location %q, want %q", w.Header().Get("Location"), want)
+			}
+			if cookieValue(w, "access_token") != "" {
+				t.Error("callback signed the browser in")
+			}
+		})
+	}
+	if len(oauth.logins) != 0 {
+		t.Errorf("got %d logins, want none", len(oauth.logins))
+	}
+}
+
+func TestOAuthCallbackRejectsMismatchedPKCEVerifier(t *testing.T) {
+	for _, provider := range []string{"oidc", "google"} {
+		t.Run(provider, func(t *testing.T) {
+			r, stub, oauth := setupOAuth(t)
+
+			// The attacker's code is bound to the attacker's PKCE challenge,
+			// so it cannot be redeemed with the verifier in the victim's
+			// flow session.
+			attacker := &oauthBrowser{}
+			attackerURL := attacker.mustBegin(t, r, provider)
+			if attackerURL.Query().Get("code_challenge") == "" {
+				t.Fatal("authorization URL has no PKCE challenge")
+			}
+			code := stub.authorize(attackerURL.String(), "attacker", "attacker@example.com")
+
+			victim := &oauthBrowser{}
+			victimURL := victim.mustBegin(t, r, provider)
+			w := victim.callback(r, provider, code, victimURL.Query().Get("state"))
+
+			if want := testFrontendURL + "?error=oauth_failed"; w.Header().Get("Location") != want {
+				t.Errorf("location %q, want %q", w.Header().Get("Location"), want)
+			}
+			if cookieValue(w, "access_token") != "" {
+				t.Error("callback signed the browser in")
+			}
+			if stub.verifierMismatches == 0 {
+				t.Error("token endpoint never saw a mismatched code_verifier")
+			}
+			if len(oauth.logins) != 0 {
+				t.Errorf("got %d logins, want none", len(oauth.logins))
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) CreateOrganization(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	org, err := h.svc.Organizations().CreateOrganization(ctx, currentUser.ID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Organization created successfully", "organization": org})
+}
+
+func (h *MainHandler) GetOrganizations(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgs, err := h.svc.Organizations().GetUserOrganizations(ctx, currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if orgs == nil {
+		orgs = []*models.Organization{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organizations retrieved successfully", "organizations": orgs, "active_org_id": currentUser.ActiveOrgID})
+}
+
+func (h *MainHandler) GetOrganizationMembers(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	if orgID == 0 {
+		c.Error(errors.BadRequest("Invalid organization ID", nil))
+		return
+	}
+
+	members, err := h.svc.Organizations().GetMembers(ctx, orgID, principal)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if members == nil {
+		members = []*models.Membership{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Members retrieved successfully", "members": members})
+}
+
+func (h *MainHandler) UpdateOrganizationMember(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	if orgID == 0 || userID == 0 {
+		c.Error(errors.BadRequest("Invalid organization or user ID", nil))
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	if err := h.svc.Organizations().UpdateMemberRole(ctx, orgID, currentUser.ID, userID, req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
+func (h *MainHandler) RemoveOrganizationMember(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	if orgID == 0 || userID == 0 {
+		c.Error(errors.BadRequest("Invalid organization or user ID", nil))
+		return
+	}
+
+	if err := h.svc.Organizations().RemoveMember(ctx, orgID, currentUser.ID, userID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *MainHandler) InviteOrganizationMember(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	if orgID == 0 {
+		c.Error(errors.BadRequest("Invalid organization ID", nil))
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	if err := h.svc.Organizations().InviteMember(ctx, orgID, currentUser.ID, req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+}
+
+func (h *MainHandler) AcceptOrganizationInvitation(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	org, err := h.svc.Organizations().AcceptInvitation(ctx, currentUser.ID, req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully", "organization": org})
+}
+
+// SwitchOrganization reissues the session cookies with the organization as
+// the active org claim.
+func (h *MainHandler) SwitchOrganization(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	if orgID == 0 {
+		c.Error(errors.BadRequest("Invalid organization ID", nil))
+		return
+	}
+
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil || refreshToken == "" {
+		c.Error(errors.Unauthorized("Refresh token not found", err))
+		return
+	}
+
+	accessToken, newRefreshToken, err := h.svc.Auth().SwitchOrganization(ctx, refreshToken, orgID, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.setAuthCookies(c, accessToken, newRefreshToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Active organization switched successfully", "active_org_id": orgID})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+)
+
+const samlRequestCookie = "saml_request_id"
+
+func (h *MainHandler) SAMLMetadata(c *gin.Context) {
+	connection, err := utils.GetSAMLConnection(c.Param("connection"))
+	if err != nil {
+		c.Error(errors.NotFound("SAML connection not found", err))
+		return
+	}
+
+	data, err := xml.MarshalIndent(connection.SP.Metadata(), "", "  ")
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to render metadata", err))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", data)
+}
+
+func (h *MainHandler) SAMLLogin(c *gin.Context) {
+	connection, err := utils.GetSAMLConnection(c.Param("connection"))
+	if err != nil {
+		c.Error(errors.NotFound("SAML connection not found", err))
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if !utils.IsAllowedRedirect(returnTo) {
+		returnTo = ""
+	}
+
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to start SAML login", err))
+		return
+	}
+
+	relayState, err := utils.SignOAuthState(utils.OAuthState{
+		Nonce:     nonce,
+		Provider:  connection.ProviderName(),
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(oauthFlowTTL).Unix(),
+	})
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to start SAML login", err))
+		return
+	}
+
+	sp := connection.SP
+	authnRequest, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to start SAML login", err))
+		return
+	}
+
+	redirectURL, err := authnRequest.Redirect(relayState, sp)
+	if err != nil {
+		c.Error(errors.InternalServerError("failed to start SAML login", err))
+		return
+	}
+
+	// The IdP posts the response cross-site, so the request ID cookie has to
+	// be SameSite=None.
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     samlRequestCookie,
+		Value:    authnRequest.ID,
+		Path:     sp.AcsURL.Path,
+		MaxAge:   int(oauthFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+func (h *MainHandler) SAMLACS(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	connection, err := utils.GetSAMLConnection(c.Param("connection"))
+	if err != nil {
+		c.Error(errors.NotFound("SAML connection not found", err))
+		return
+	}
+
+	redirectURL := utils.FrontendURL()
+	if relayState := c.PostForm("RelayState"); relayState != "" {
+		state, err := utils.VerifyOAuthState(relayState)
+		if err != nil || state.Provider != connection.ProviderName() {
+			oauthRedirectError(c, redirectURL, "invalid_state", errors.Unauthorized("SAML relay state mismatch", err))
+			return
+		}
+		redirectURL = utils.ResolveRedirectURL(state.ReturnTo)
+	}
+
+	var possibleRequestIDs []string
+	if requestID, err := c.Cookie(samlRequestCookie); err == nil && requestID != "" {
+		possibleRequestIDs = append(possibleRequestIDs, requestID)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     samlRequestCookie,
+		Path:     connection.SP.AcsURL.Path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	assertion, err := connection.SP.ParseResponse(c.Request, possibleRequestIDs)
+	if err != nil {
+		if invalid, ok := err.(*saml.InvalidResponseError); ok {
+			err = invalid.PrivateErr
+		}
+		oauthRedirectError(c, redirectURL, "saml_failed", err)
+		return
+	}
+
+	data := connection.MapAssertion(assertion)
+	if data.OAuthID == "" || data.Email == "" {
+		oauthRedirectError(c, redirectURL, "invalid_request", errors.BadRequest("assertion is missing the name id or email", nil))
+		return
+	}
+
+	userData, err := h.svc.OAuth().OAuthLogin(ctx, data, connection.VouchesFor(data.Email))
+	if err != nil {
+		oauthRedirectError(c, redirectURL, oauthErrorCode(err), err)
+		return
+	}
+
+	userData.IPAddress = c.ClientIP()
+	userData.UserAgent = c.GetHeader("User-Agent")
+	accessToken, refreshToken, err := h.svc.Auth().IssueTokens(ctx, *userData)
+	if err != nil {
+		oauthRedirectError(c, redirectURL, oauthErrorCode(err), err)
+		return
+	}
+
+	h.setAuthCookies(c, accessToken, refreshToken)
+	c.Redirect(http.StatusFound, redirectURL)
+}
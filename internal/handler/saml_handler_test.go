@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/middleware"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+)
+
+// newKeyPair generates a throwaway RSA key and self-signed certificate.
+func newKeyPair(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+func newTestIDP(t *testing.T) *saml.IdentityProvider {
+	key, cert := newKeyPair(t, "idp.acme.test")
+	metadataURL, _ := url.Parse("https://idp.acme.test/metadata")
+	ssoURL, _ := url.Parse("https://idp.acme.test/sso")
+	return &saml.IdentityProvider{
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+}
+
+// setupSAML registers an "acme" connection trusted for acme.test that
+// accepts assertions signed by idp.
+func setupSAML(t *testing.T, idp *saml.IdentityProvider) (*gin.Engine, *saml.ServiceProvider, *fakeOAuthService) {
+	gin.SetMode(gin.TestMode)
+	utils.UseSecrets(utils.Secrets{JWTAccess: "access", JWTRefresh: "refresh", Session: "session-secret"})
+	utils.UseRedirects(testFrontendURL, nil)
+
+	key, cert := newKeyPair(t, "api.test")
+	metadataURL, _ := url.Parse("http://api.test/api/saml/acme/metadata")
+	acsURL, _ := url.Parse("http://api.test/api/saml/acme/acs")
+	sp := &saml.ServiceProvider{
+		EntityID:    metadataURL.String(),
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idp.Metadata(),
+	}
+	utils.UseSAMLConnections(&utils.SAMLConnection{
+		Name:    "acme",
+		SP:      sp,
+		Mapping: utils.SAMLAttributeMapping{Email: "eduPersonPrincipalName"},
+		Domains: []string{"acme.test"},
+	})
+
+	oauth := &fakeOAuthService{}
+	h := NewMainHandler(&fakeService{oauth: oauth}, config.Server{CookieDomain: "api.test"})
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.GET("/api/saml/:connection/login", h.SAMLLogin)
+	r.POST("/api/saml/:connection/acs", h.SAMLACS)
+	return r, sp, oauth
+}
+
+// samlLogin starts a login and returns the form the IdP would post back for
+// email, signed by signer, along with the browser's request ID cookie.
+func samlLogin(t *testing.T, r *gin.Engine, sp *saml.ServiceProvider, signer *saml.IdentityProvider, email string) (url.Values, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/saml/acme/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status %d, body %s", w.Code, w.Body)
+	}
+	var requestCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == samlRequestCookie {
+			requestCookie = cookie
+		}
+	}
+	if requestCookie == nil {
+		t.Fatal("login did not set the request ID cookie")
+	}
+
+	idpReq, err := saml.NewIdpAuthnRequest(signer, httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(idpReq.RequestBuffer, &idpReq.Request); err != nil {
+		t.Fatal(err)
+	}
+	// Leave out the SP's encryption key so the signed assertion stays
+	// readable and a test can tamper with it.
+	idpReq.ServiceProviderMetadata = sp.Metadata()
+	idpReq.SPSSODescriptor = &saml.SPSSODescriptor{}
+	idpReq.ACSEndpoint = &saml.IndexedEndpoint{Binding: saml.HTTPPostBinding, Location: sp.AcsURL.String()}
+
+	err = saml.DefaultAssertionMaker{}.MakeAssertion(idpReq, &saml.Session{
+		ID:         "session-1",
+		CreateTime: time.Now(),
+		ExpireTime: time.Now().Add(time.Hour),
+		NameID:     "user-42",
+		UserName:   "alice",
+		UserEmail:  email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := idpReq.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}, requestCookie
+}
+
+func postACS(r *gin.Engine, form url.Values, requestCookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/saml/acme/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(requestCookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSAMLACSVouchesOnlyForConnectionDomains(t *testing.T) {
+	tests := []struct {
+		email    string
+		verified bool
+	}{
+		{"alice@acme.test", true},
+		{"alice@ACME.test", true},
+		{"victim@other.test", false},
+		{"victim@sub.acme.test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			idp := newTestIDP(t)
+			r, sp, oauth := setupSAML(t, idp)
+			form, requestCookie := samlLogin(t, r, sp, idp, tt.email)
+
+			w := postACS(r, form, requestCookie)
+			if w.Code != http.StatusFound || w.Header().Get("Location") != testFrontendURL {
+				t.Fatalf("status %d, location %q", w.Code, w.Header().Get("Location"))
+			}
+			if len(oauth.logins) != 1 {
+				t.Fatalf("got %d logins, want 1", len(oauth.logins))
+			}
+			if got := oauth.logins[0]; got.Email != tt.email || got.OAuthID != "user-42" || got.Provider != "saml:acme" {
+				t.Errorf("login for %+v", got)
+			}
+			if oauth.verified[0] != tt.verified {
+				t.Errorf("email verified = %v, want %v", oauth.verified[0], tt.verified)
+			}
+		})
+	}
+}
+
+func TestSAMLACSRejectsInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer func(t *testing.T, idp *saml.IdentityProvider) *saml.IdentityProvider
+		tamper func(t *testing.T, form url.Values)
+	}{
+		{
+			name: "assertion edited after signing",
+			tamper: func(t *testing.T, form url.Values) {
+				response, err := base64.StdEncoding.DecodeString(form.Get("SAMLResponse"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tampered := strings.ReplaceAll(string(response), "alice@acme.test", "admin@acme.test")
+				if tampered == string(response) {
+					t.Fatal("email not found in response")
+				}
+				form.Set("SAMLResponse", base64.StdEncoding.EncodeToString([]byte(tampered)))
+			},
+		},
+		{
+			name: "signed by another IdP",
+			signer: func(t *testing.T, idp *saml.IdentityProvider) *saml.IdentityProvider {
+				impostor := newTestIDP(t)
+				impostor.MetadataURL = idp.MetadataURL
+				return impostor
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIDP(t)
+			r, sp, oauth := setupSAML(t, idp)
+
+			signer := idp
+			if tt.signer != nil {
+				signer = tt.signer(t, idp)
+			}
+			form, requestCookie := samlLogin(t, r, sp, signer, "alice@acme.test")
+			if tt.tamper != nil {
+				tt.tamper(t, form)
+			}
+
+			w := postACS(r, form, requestCookie)
+			if want := testFrontendURL + "?error=saml_failed"; w.Header().Get("Location") != want {
+				t.Errorf("location %q, want %q", w.Header().Get("Location"), want)
+			}
+			if cookieValue(w, "access_token") != "" {
+				t.Error("ACS signed the browser in")
+			}
+			if len(oauth.logins) != 0 {
+				t.Errorf("got %d logins, want none", len(oauth.logins))
+			}
+		})
+	}
+}
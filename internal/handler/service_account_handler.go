@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) CreateServiceAccount(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	if orgID == 0 {
+		c.Error(errors.BadRequest("Invalid organization ID", nil))
+		return
+	}
+
+	var req models.CreateServiceAccountRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	account, err := h.svc.ServiceAccounts().CreateServiceAccount(ctx, orgID, currentUser.ID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Service account created successfully", "service_account": account})
+}
+
+func (h *MainHandler) GetServiceAccounts(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	if orgID == 0 {
+		c.Error(errors.BadRequest("Invalid organization ID", nil))
+		return
+	}
+
+	accounts, err := h.svc.ServiceAccounts().GetServiceAccounts(ctx, orgID, currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if accounts == nil {
+		accounts = []*models.ServiceAccount{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service accounts retrieved successfully", "service_accounts": accounts})
+}
+
+func (h *MainHandler) DeleteServiceAccount(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	if orgID == 0 || accountID == 0 {
+		c.Error(errors.BadRequest("Invalid organization or service account ID", nil))
+		return
+	}
+
+	if err := h.svc.ServiceAccounts().DeleteServiceAccount(ctx, orgID, currentUser.ID, accountID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+func (h *MainHandler) CreateAPIKey(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	if orgID == 0 || accountID == 0 {
+		c.Error(errors.BadRequest("Invalid organization or service account ID", nil))
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	key, plaintext, err := h.svc.ServiceAccounts().CreateKey(ctx, orgID, currentUser.ID, accountID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "API key created successfully, it will not be shown again", "key": plaintext, "details": key})
+}
+
+func (h *MainHandler) GetAPIKeys(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	if orgID == 0 || accountID == 0 {
+		c.Error(errors.BadRequest("Invalid organization or service account ID", nil))
+		return
+	}
+
+	keys, err := h.svc.ServiceAccounts().GetKeys(ctx, orgID, currentUser.ID, accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API keys retrieved successfully", "keys": keys})
+}
+
+func (h *MainHandler) RotateAPIKey(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	keyID, _ := strconv.Atoi(c.Param("keyId"))
+	if orgID == 0 || accountID == 0 || keyID == 0 {
+		c.Error(errors.BadRequest("Invalid organization, service account or key ID", nil))
+		return
+	}
+
+	var req models.RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+			return
+		}
+	}
+
+	key, plaintext, err := h.svc.ServiceAccounts().RotateKey(ctx, orgID, currentUser.ID, accountID, keyID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "API key rotated successfully, it will not be shown again", "key": plaintext, "details": key})
+}
+
+func (h *MainHandler) RevokeAPIKey(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	keyID, _ := strconv.Atoi(c.Param("keyId"))
+	if orgID == 0 || accountID == 0 || keyID == 0 {
+		c.Error(errors.BadRequest("Invalid organization, service account or key ID", nil))
+		return
+	}
+
+	if err := h.svc.ServiceAccounts().RevokeKey(ctx, orgID, currentUser.ID, accountID, keyID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+func (h *MainHandler) GetAPIKeyUsage(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	accountID, _ := strconv.Atoi(c.Param("accountId"))
+	keyID, _ := strconv.Atoi(c.Param("keyId"))
+	if orgID == 0 || accountID == 0 || keyID == 0 {
+		c.Error(errors.BadRequest("Invalid organization, service account or key ID", nil))
+		return
+	}
+
+	usage, err := h.svc.ServiceAccounts().GetKeyUsage(ctx, orgID, currentUser.ID, accountID, keyID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if usage == nil {
+		usage = []*models.APIKeyUsage{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key usage retrieved successfully", "usage": usage})
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) CreateToken(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.CreateTokenRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	token, plaintext, err := h.svc.Tokens().CreateToken(ctx, currentUser.ID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Token created successfully, it will not be shown again", "token": plaintext, "details": token})
+}
+
+func (h *MainHandler) GetTokens(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	tokens, err := h.svc.Tokens().GetTokens(ctx, currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if tokens == nil {
+		tokens = []*models.PersonalAccessToken{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tokens retrieved successfully", "tokens": tokens})
+}
+
+func (h *MainHandler) RevokeToken(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid token ID", nil))
+		return
+	}
+
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	if err := h.svc.Tokens().RevokeToken(ctx, currentUser.ID, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MainHandler) CreateWebhookEndpoint(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	currentUser, err := utils.GetUser(c)
+	if err != nil {
+		c.Error(errors.Unauthorized("User is not authenticated", err))
+		return
+	}
+
+	var req models.CreateWebhookEndpointRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	endpoint, secret, err := h.svc.Webhooks().CreateEndpoint(ctx, currentUser.ID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Webhook endpoint created successfully, the secret will not be shown again", "secret": secret, "endpoint": endpoint})
+}
+
+func (h *MainHandler) GetWebhookEndpoints(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+
+	endpoints, err := h.svc.Webhooks().GetEndpoints(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if endpoints == nil {
+		endpoints = []*models.WebhookEndpoint{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoints retrieved successfully", "endpoints": endpoints})
+}
+
+func (h *MainHandler) UpdateWebhookEndpoint(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid webhook endpoint ID", nil))
+		return
+	}
+
+	var req models.UpdateWebhookEndpointRequest
+	if isValid := utils.BindJSONWithValidation(c, &req); !isValid {
+		return
+	}
+
+	if err := h.svc.Webhooks().SetEndpointActive(ctx, id, *req.Active); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint updated successfully"})
+}
+
+func (h *MainHandler) DeleteWebhookEndpoint(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid webhook endpoint ID", nil))
+		return
+	}
+
+	if err := h.svc.Webhooks().DeleteEndpoint(ctx, id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted successfully"})
+}
+
+func (h *MainHandler) GetWebhookDeliveries(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	if id == 0 {
+		c.Error(errors.BadRequest("Invalid webhook endpoint ID", nil))
+		return
+	}
+
+	deliveries, err := h.svc.Webhooks().GetDeliveries(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deliveries retrieved successfully", "deliveries": deliveries})
+}
+
+func (h *MainHandler) RedeliverWebhook(c *gin.Context) {
+	ctx, cancel := utils.CtxWithTimeOut(c)
+	defer cancel()
+	id, _ := strconv.Atoi(c.Param("id"))
+	deliveryID, _ := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if id == 0 || deliveryID == 0 {
+		c.Error(errors.BadRequest("Invalid webhook endpoint or delivery ID", nil))
+		return
+	}
+
+	delivery, err := h.svc.Webhooks().Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Webhook redelivery queued successfully", "delivery": delivery})
+}
@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every message as a file into the maildir at dir,
+// creating it when needed. Mail clients such as mutt can open it directly.
+func NewFileMailer(dir, from string) (Mailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %v", err)
+		}
+	}
+	if from == "" {
+		from = "auth-go@localhost"
+	}
+
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+// Send writes into tmp and then renames into new, so readers never see a
+// partial message.
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := encode(msg, m.from)
+	if err != nil {
+		return err
+	}
+
+	random, err := utils.RandomToken(8)
+	if err != nil {
+		return fmt.Errorf("failed to name message file: %v", err)
+	}
+	name := fmt.Sprintf("%d.%s.auth-go", time.Now().UnixNano(), random)
+
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to deliver message: %v", err)
+	}
+	return nil
+}
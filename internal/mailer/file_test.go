@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesMaildirMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "auth-go@example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := Message{To: "alice@example.test", Subject: "Verify your email", HTML: "<p>Hi Alice</p>", Text: "Hi Alice"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("%d files left in tmp", len(tmp))
+	}
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil || len(delivered) != 1 {
+		t.Fatalf("new holds %d messages (%v), want 1", len(delivered), err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "new", delivered[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := string(data)
+	for _, want := range []string{
+		"From: auth-go@example.test",
+		"To: alice@example.test",
+		"Subject: Verify your email",
+		"multipart/alternative",
+		"Hi Alice",
+		"<p>Hi Alice</p>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("message is missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestFileMailerNeedsASender(t *testing.T) {
+	m := &fileMailer{dir: t.TempDir()}
+	if err := m.Send(context.Background(), Message{To: "alice@example.test", Text: "Hi"}); err == nil {
+		t.Error("message without a sender was written")
+	}
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+type logMailer struct {
+	from string
+}
+
+// NewLogMailer prints messages to the application log instead of sending
+// them. It is meant for development, where links can be copied from the log.
+func NewLogMailer(from string) Mailer {
+	return &logMailer{from: from}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	body := msg.Text
+	if body == "" {
+		body = msg.HTML
+	}
+	log.Printf("mail from=%q to=%q subject=%q\n%s", msg.From, msg.To, msg.Subject, body)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package mailer sends email through a configurable transport: SMTP, a
+// maildir on disk, the application log or memory.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	TransportSMTP = "smtp"
+	TransportFile = "file"
+	TransportLog  = "log"
+)
+
+// Message is a single email. When both HTML and Text are set the message is
+// sent as multipart/alternative. An empty From uses the transport's sender.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// PermanentError is returned when the mail server rejects a message in a
+// way that retrying will not fix, such as an unknown recipient.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// IsPermanent reports whether err is or wraps a PermanentError.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// New returns the transport selected by cfg.Transport.
+func New(cfg config.Mailer) (Mailer, error) {
+	switch cfg.Transport {
+	case TransportSMTP:
+		return NewSMTPMailer(cfg)
+	case TransportFile:
+		return NewFileMailer(cfg.Dir, cfg.From)
+	case TransportLog:
+		return NewLogMailer(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+	}
+}
+
+// encode renders msg as an RFC 5322 message.
+func encode(msg Message, from string) ([]byte, error) {
+	if msg.From == "" {
+		msg.From = from
+	}
+	if msg.From == "" {
+		return nil, fmt.Errorf("no sender address, set MAIL_FROM")
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetDateHeader("Date", time.Now())
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		m.SetBody("text/plain", msg.Text)
+		m.AddAlternative("text/html", msg.HTML)
+	case msg.HTML != "":
+		m.SetBody("text/html", msg.HTML)
+	default:
+		m.SetBody("text/plain", msg.Text)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %v", err)
+	}
+	return buf.Bytes(), nil
+}
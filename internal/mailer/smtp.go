@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+
+	"github.com/Jonathan0823/auth-go/config"
+)
+
+// SMTP TLS modes. TLSImplicit connects over TLS (usually port 465),
+// TLSStartTLS requires the server to upgrade the connection with STARTTLS
+// (usually port 587) and TLSNone sends in plain text.
+const (
+	TLSImplicit = "tls"
+	TLSStartTLS = "starttls"
+	TLSNone     = "none"
+)
+
+type smtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	tlsMode  string
+	from     string
+}
+
+func NewSMTPMailer(cfg config.Mailer) (Mailer, error) {
+	if cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail transport")
+	}
+	if cfg.SMTPPort <= 0 || cfg.SMTPPort > 65535 {
+		return nil, fmt.Errorf("invalid SMTP_PORT")
+	}
+	switch cfg.SMTPTLS {
+	case TLSImplicit, TLSStartTLS, TLSNone:
+	default:
+		return nil, fmt.Errorf("invalid SMTP_TLS %q, expected tls, starttls or none", cfg.SMTPTLS)
+	}
+
+	return &smtpMailer{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		tlsMode:  cfg.SMTPTLS,
+		from:     cfg.From,
+	}, nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	body, err := encode(msg, m.from)
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("smtp auth failed: %v", err)
+		}
+	}
+	if err := client.Mail(msg.From); err != nil {
+		return smtpError("smtp MAIL FROM", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return smtpError("smtp RCPT TO", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("smtp DATA", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("smtp message", err)
+	}
+	return client.Quit()
+}
+
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	tlsConfig := &tls.Config{ServerName: m.host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.tlsMode == TLSImplicit {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start smtp session: %v", err)
+	}
+	if m.tlsMode == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %v", err)
+		}
+	}
+	return client, nil
+}
+
+// smtpError wraps the error of an SMTP command. 5xx replies are permanent;
+// anything else, including 4xx replies and network errors, may be retried.
+func smtpError(command string, err error) error {
+	wrapped := fmt.Errorf("%s failed: %w", command, err)
+	if protoErr, ok := err.(*textproto.Error); ok && protoErr.Code >= 500 {
+		return &PermanentError{Err: wrapped}
+	}
+	return wrapped
+}
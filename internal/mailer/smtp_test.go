@@ -0,0 +1,151 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+)
+
+// smtpStandIn accepts one connection and records what the client did. With
+// implicitTLS it only reads the first byte, a TLS handshake starts with
+// 0x16; otherwise it speaks enough SMTP to take one message.
+type smtpStandIn struct {
+	implicitTLS bool
+	startTLS    bool
+
+	done     chan struct{}
+	tlsHello bool
+	upgraded bool
+	data     string
+}
+
+func (s *smtpStandIn) start(t *testing.T) config.Mailer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		s.serve(conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return config.Mailer{SMTPHost: host, SMTPPort: portNumber, From: "auth-go@example.test"}
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if s.implicitTLS {
+		first, _ := r.ReadByte()
+		s.tlsHello = first == 0x16
+		return
+	}
+
+	conn.Write([]byte("220 localhost ESMTP\r\n"))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(line + " x")[0])
+		switch command {
+		case "EHLO":
+			if s.startTLS {
+				conn.Write([]byte("250-localhost\r\n250 STARTTLS\r\n"))
+			} else {
+				conn.Write([]byte("250 localhost\r\n"))
+			}
+		case "STARTTLS":
+			s.upgraded = true
+			conn.Write([]byte("220 ready\r\n"))
+			first, _ := r.ReadByte()
+			s.tlsHello = first == 0x16
+			return
+		case "DATA":
+			conn.Write([]byte("354 go ahead\r\n"))
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data = data.String()
+			conn.Write([]byte("250 queued\r\n"))
+		case "QUIT":
+			conn.Write([]byte("221 bye\r\n"))
+			return
+		default:
+			conn.Write([]byte("250 ok\r\n"))
+		}
+	}
+}
+
+func TestSMTPMailerTLSModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		server       smtpStandIn
+		wantErr      bool
+		wantTLS      bool
+		wantUpgrade  bool
+		wantDelivery bool
+	}{
+		{name: "implicit TLS handshakes first", mode: TLSImplicit, server: smtpStandIn{implicitTLS: true}, wantErr: true, wantTLS: true},
+		{name: "STARTTLS upgrades the session", mode: TLSStartTLS, server: smtpStandIn{startTLS: true}, wantErr: true, wantTLS: true, wantUpgrade: true},
+		{name: "STARTTLS is required", mode: TLSStartTLS, server: smtpStandIn{}, wantErr: true},
+		{name: "none sends in plain text", mode: TLSNone, server: smtpStandIn{startTLS: true}, wantDelivery: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.server
+			cfg := server.start(t)
+			cfg.SMTPTLS = tt.mode
+			m, err := NewSMTPMailer(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The stand-in's certificate is never trusted, so a TLS
+			// handshake fails after the client has started it.
+			err = m.Send(context.Background(), Message{To: "alice@example.test", Subject: "Hello", Text: "Hi Alice"})
+			<-server.done
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if server.tlsHello != tt.wantTLS {
+				t.Errorf("client started TLS %t, want %t", server.tlsHello, tt.wantTLS)
+			}
+			if server.upgraded != tt.wantUpgrade {
+				t.Errorf("client sent STARTTLS %t, want %t", server.upgraded, tt.wantUpgrade)
+			}
+			if delivered := strings.Contains(server.data, "Hi Alice"); delivered != tt.wantDelivery {
+				t.Errorf("message delivered %t, want %t", delivered, tt.wantDelivery)
+			}
+		})
+	}
+}
+
+func TestNewSMTPMailerRejectsUnknownTLSMode(t *testing.T) {
+	if _, err := NewSMTPMailer(config.Mailer{SMTPHost: "localhost", SMTPPort: 25, SMTPTLS: "ssl"}); err == nil {
+		t.Error("unknown SMTP_TLS was accepted")
+	}
+}
@@ -0,0 +1,267 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/Jonathan0823/auth-go/config"
+)
+
+// Template names. Every name has an HTML and a text template per locale.
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplateResetPassword   = "reset_password"
+	TemplateMagicLink       = "magic_link"
+	TemplatePasswordChanged = "password_changed"
+	TemplateNewDevice       = "new_device"
+	TemplateInvitation      = "invitation"
+)
+
+var TemplateNames = []string{
+	TemplateVerifyEmail,
+	TemplateResetPassword,
+	TemplateMagicLink,
+	TemplatePasswordChanged,
+	TemplateNewDevice,
+	TemplateInvitation,
+}
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// Branding is available to every template as .Brand.
+type Branding struct {
+	ProductName  string
+	LogoURL      string
+	PrimaryColor string
+	SupportEmail string
+	BaseURL      string
+}
+
+// Templates renders transactional emails. Each locale is a directory holding
+// <name>.html and <name>.txt, which define "content" for layout.html and
+// layout.txt; the text template also defines "subject". Files in the
+// override directory replace the embedded file with the same path, and new
+// locale directories there add locales.
+type Templates struct {
+	brand         Branding
+	defaultLocale string
+	html          map[string]*htmltemplate.Template
+	text          map[string]*texttemplate.Template
+}
+
+// NewTemplates parses the embedded templates and the overrides in
+// cfg.TemplatesDir, failing on the first template that does not parse.
+func NewTemplates(cfg config.Mailer) (*Templates, error) {
+	sources := []fs.FS{}
+	if cfg.TemplatesDir != "" {
+		if _, err := os.Stat(cfg.TemplatesDir); err != nil {
+			return nil, fmt.Errorf("invalid MAIL_TEMPLATES_DIR: %v", err)
+		}
+		sources = append(sources, os.DirFS(cfg.TemplatesDir))
+	}
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, embedded)
+
+	t := &Templates{
+		brand: Branding{
+			ProductName:  cfg.ProductName,
+			LogoURL:      cfg.LogoURL,
+			PrimaryColor: cfg.PrimaryColor,
+			SupportEmail: cfg.SupportEmail,
+			BaseURL:      cfg.BaseURL,
+		},
+		defaultLocale: normalizeLocale(cfg.DefaultLocale),
+		html:          map[string]*htmltemplate.Template{},
+		text:          map[string]*texttemplate.Template{},
+	}
+
+	htmlLayout, err := readFirst(sources, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+	textLayout, err := readFirst(sources, "layout.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, locale := range localeDirs(sources) {
+		for _, name := range TemplateNames {
+			key := locale + "/" + name
+			htmlPage, htmlErr := readFirst(sources, key+".html")
+			textPage, textErr := readFirst(sources, key+".txt")
+			if htmlErr != nil || textErr != nil {
+				// Missing translations fall back to the default locale.
+				continue
+			}
+
+			html, err := htmltemplate.New(name).Parse(string(htmlLayout))
+			if err == nil {
+				html, err = html.Parse(string(htmlPage))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s.html: %v", key, err)
+			}
+			text, err := texttemplate.New(name).Parse(string(textLayout))
+			if err == nil {
+				text, err = text.Parse(string(textPage))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s.txt: %v", key, err)
+			}
+			t.html[key] = html
+			t.text[key] = text
+		}
+	}
+
+	for _, name := range TemplateNames {
+		if _, ok := t.html[t.defaultLocale+"/"+name]; !ok {
+			return nil, fmt.Errorf("template %s is missing for the default locale %q", name, t.defaultLocale)
+		}
+	}
+
+	return t, nil
+}
+
+// DefaultTemplates returns the embedded templates with neutral branding.
+func DefaultTemplates() *Templates {
+	t, err := NewTemplates(config.Mailer{ProductName: "auth-go", DefaultLocale: "en"})
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render renders the named template in locale, falling back to the
+// language without its region and then to the default locale. vars are
+// available to the template next to .Brand and .Locale. The returned
+// message has no recipient.
+func (t *Templates) Render(name, locale string, vars map[string]any) (Message, error) {
+	locale = t.resolve(name, locale)
+	key := locale + "/" + name
+	html, ok := t.html[key]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	data := make(map[string]any, len(vars)+2)
+	for k, v := range vars {
+		data[k] = v
+	}
+	data["Brand"] = t.brand
+	data["Locale"] = locale
+
+	var subject, text, body bytes.Buffer
+	if err := t.text[key].ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %v", key, err)
+	}
+	if err := t.text[key].ExecuteTemplate(&text, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s.txt: %v", key, err)
+	}
+	if err := html.ExecuteTemplate(&body, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s.html: %v", key, err)
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    body.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// Locales returns every locale that has at least one template.
+func (t *Templates) Locales() []string {
+	var locales []string
+	for key := range t.html {
+		locale, _, _ := strings.Cut(key, "/")
+		if !slices.Contains(locales, locale) {
+			locales = append(locales, locale)
+		}
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+func (t *Templates) resolve(name, locale string) string {
+	locale = normalizeLocale(locale)
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, language} {
+		if _, ok := t.html[candidate+"/"+name]; ok && candidate != "" {
+			return candidate
+		}
+	}
+	return t.defaultLocale
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// readFirst reads name from the first source that has it.
+func readFirst(sources []fs.FS, name string) ([]byte, error) {
+	for _, source := range sources {
+		data, err := fs.ReadFile(source, name)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+	}
+	return nil, fmt.Errorf("template %s not found", name)
+}
+
+func localeDirs(sources []fs.FS) []string {
+	var locales []string
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source, ".")
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			locale := normalizeLocale(path.Base(entry.Name()))
+			if entry.IsDir() && !slices.Contains(locales, locale) {
+				locales = append(locales, locale)
+			}
+		}
+	}
+	return locales
+}
+
+// PreviewVars returns sample variables for the named template, used by the
+// admin preview endpoint.
+func PreviewVars(name string, brand Branding) map[string]any {
+	link := brand.BaseURL + "/example?id=00000000-0000-0000-0000-000000000000"
+	vars := map[string]any{
+		"Name": "Jane Doe",
+		"URL":  link,
+	}
+	switch name {
+	case TemplatePasswordChanged, TemplateNewDevice:
+		vars["Time"] = "2025-01-01 09:30 UTC"
+		vars["IPAddress"] = "203.0.113.7"
+		vars["UserAgent"] = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Firefox/125.0"
+		vars["URL"] = brand.BaseURL + "/forgot-password"
+	case TemplateInvitation:
+		vars["InviterName"] = "John Smith"
+		vars["OrganizationName"] = "Example Inc."
+	}
+	return vars
+}
+
+// Brand returns the branding passed to every template.
+func (t *Templates) Brand() Branding {
+	return t.brand
+}
@@ -2,32 +2,214 @@
 package middleware
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
 	"github.com/Jonathan0823/auth-go/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// requestIDPattern limits the request IDs accepted from clients and proxies.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags the request with the incoming X-Request-ID, or a new one,
+// echoes it in the response and stores it with the client IP and user agent
+// in the request context for audit events.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Header("X-Request-ID", requestID)
+
+		ctx := utils.WithRequestMetadata(c.Request.Context(), utils.RequestMetadata{
+			RequestID: requestID,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// AuthMiddleware authenticates the request with an `Authorization: Bearer`
+// header, which may hold an access token, a personal access token or a
+// service account API key, or with the access token cookie. Users whose
+// account status no longer allows access are rejected.
+func AuthMiddleware(svc service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := c.Cookie("access_token")
-		if err != nil || token == "" {
-			c.Error(errors.Unauthorized("Unauthorized: missing token", err))
+		ctx, cancel := utils.CtxWithTimeOut(c)
+		claims, err := authenticate(ctx, c, svc)
+		cancel()
+		if err != nil {
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		user, err := utils.ValidateJWT(token, "access")
+		c.Set("user", claims)
+		setRequestActor(c, claims)
+
+		c.Next()
+	}
+}
+
+// setRequestActor records the user or service account behind the request for
+// audit events. An impersonating admin is the actor, not the impersonated
+// user.
+func setRequestActor(c *gin.Context, claims jwt.MapClaims) {
+	metadata := utils.GetRequestMetadata(c.Request.Context())
+	id, _ := claims["id"].(float64)
+	actorID := int(id)
+	if claims["principal_type"] == models.PrincipalServiceAccount {
+		metadata.ServiceAccountID = &actorID
+	} else {
+		if act, ok := claims["act"].(map[string]any); ok {
+			sub, _ := act["sub"].(string)
+			actorID, _ = strconv.Atoi(sub)
+		}
+		metadata.ActorID = &actorID
+	}
+	c.Request = c.Request.WithContext(utils.WithRequestMetadata(c.Request.Context(), metadata))
+}
+
+func authenticate(ctx context.Context, c *gin.Context, svc service.Service) (jwt.MapClaims, error) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		cookie, err := c.Cookie("access_token")
+		if err != nil || cookie == "" {
+			return nil, errors.Unauthorized("Unauthorized: missing token", err)
+		}
+		token = cookie
+	}
+
+	if strings.HasPrefix(token, service.PATPrefix) {
+		user, pat, err := svc.Tokens().AuthenticateToken(ctx, token, c.ClientIP())
+		if err != nil {
+			return nil, err
+		}
+		if !tokenAllowsMethod(pat.Scopes, c.Request.Method) {
+			return nil, errors.Forbidden("Forbidden: token lacks the write scope", nil)
+		}
+		return utils.PrincipalClaims(*user, models.PrincipalPAT, "pat:"+strconv.Itoa(pat.ID), pat.Scopes), nil
+	}
+
+	if strings.HasPrefix(token, service.APIKeyPrefix) {
+		principal, err := svc.ServiceAccounts().AuthenticateKey(ctx, token, c.ClientIP())
 		if err != nil {
-			c.Error(errors.Unauthorized("Unauthorized: invalid token", err))
+			return nil, err
+		}
+		if !tokenAllowsMethod(principal.Scopes, c.Request.Method) {
+			return nil, errors.Forbidden("Forbidden: api key lacks the write scope", nil)
+		}
+		return utils.ServiceAccountClaims(*principal), nil
+	}
+
+	claims, err := utils.ValidateJWT(token, "access")
+	if err != nil {
+		return nil, errors.Unauthorized("Unauthorized: invalid token", err)
+	}
+
+	id, _ := claims["id"].(float64)
+	if err := svc.User().CheckUserStatus(ctx, int(id)); err != nil {
+		return nil, err
+	}
+	if _, impersonating := claims["act"]; impersonating {
+		jti, _ := claims["jti"].(string)
+		if err := svc.Impersonation().CheckImpersonation(ctx, jti); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// tokenAllowsMethod lets read scoped tokens make safe requests only.
+func tokenAllowsMethod(scopes []string, method string) bool {
+	if slices.Contains(scopes, models.ScopeWrite) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(scopes, models.ScopeRead)
+	}
+	return false
+}
+
+// RequireSession rejects requests authenticated with a personal access
+// token or an API key. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetPrincipalType(c) != models.PrincipalUser {
+			c.Error(errors.Forbidden("Forbidden: requires a login session", nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUser rejects requests authenticated as a service account, for
+// actions that are recorded against the acting user. It must run after
+// AuthMiddleware.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetPrincipalType(c) == models.PrincipalServiceAccount {
+			c.Error(errors.Forbidden("Forbidden: not allowed for service accounts", nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose principal does not carry
+// permission: a user's access token, or a token or API key scoped to it. It
+// must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.HasPermission(c, permission) {
+			c.Error(errors.Forbidden("Forbidden: missing permission "+permission, nil))
 			c.Abort()
 			return
 		}
 
-		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// BlockImpersonation rejects sensitive actions made with an impersonation
+// token. Service accounts never impersonate. It must run after
+// AuthMiddleware.
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetPrincipalType(c) == models.PrincipalServiceAccount {
+			c.Next()
+			return
+		}
+
+		user, err := utils.GetUser(c)
+		if err != nil || user.Actor != nil {
+			c.Error(errors.Forbidden("Forbidden: not allowed while impersonating", err))
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -36,13 +218,13 @@ func AuthMiddleware() gin.HandlerFunc {
 func OAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		provider := c.Param("provider")
-		if provider == "" {
-			provider = "github"
+		if _, err := goth.GetProvider(provider); err != nil {
+			c.Error(errors.NotFound("OAuth provider not found", err))
+			c.Abort()
+			return
 		}
 
-		gothic.GetProviderName = func(req *http.Request) (string, error) {
-			return provider, nil
-		}
+		c.Request = gothic.GetContextWithProvider(c.Request, provider)
 
 		c.Next()
 	}
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS link_token_nonces;
+DROP TABLE IF EXISTS email_queue;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_outbox;
+DROP TABLE IF EXISTS webhook_endpoints;
+DROP TABLE IF EXISTS api_key_usage;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS service_accounts;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS impersonation_sessions;
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS token_log;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_token_log_expired_at;
+
+ALTER TABLE token_log DROP CONSTRAINT IF EXISTS token_log_refreshed_from_jti_fkey;
+ALTER TABLE token_log ADD CONSTRAINT token_log_refreshed_from_jti_fkey
+  FOREIGN KEY (refreshed_from_jti) REFERENCES token_log(jti);
@@ -0,0 +1,6 @@
+-- Purged refresh tokens leave the tokens rotated from them without a parent.
+ALTER TABLE token_log DROP CONSTRAINT IF EXISTS token_log_refreshed_from_jti_fkey;
+ALTER TABLE token_log ADD CONSTRAINT token_log_refreshed_from_jti_fkey
+  FOREIGN KEY (refreshed_from_jti) REFERENCES token_log(jti) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_token_log_expired_at ON token_log(expired_at);
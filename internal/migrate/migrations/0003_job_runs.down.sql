@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE name = 'jobs:read';
+
+DROP TABLE IF EXISTS job_runs;
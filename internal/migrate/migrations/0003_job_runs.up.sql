@@ -0,0 +1,24 @@
+-- Runs of the scheduled background jobs, kept for the purge retention.
+CREATE TABLE job_runs (
+  id BIGSERIAL PRIMARY KEY,
+  job VARCHAR(100) NOT NULL,
+  instance VARCHAR(255) NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL CHECK (status IN ('succeeded', 'failed')),
+  rows_affected BIGINT NOT NULL DEFAULT 0,
+  result JSONB NOT NULL DEFAULT '{}',
+  error TEXT NOT NULL DEFAULT '',
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_job_runs_job ON job_runs(job, started_at DESC);
+CREATE INDEX idx_job_runs_started_at ON job_runs(started_at);
+
+INSERT INTO permissions (name, description) VALUES
+  ('jobs:read', 'View background job runs and purge statistics')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles CROSS JOIN permissions
+WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
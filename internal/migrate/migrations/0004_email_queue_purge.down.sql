@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_email_queue_created_at;
@@ -0,0 +1,2 @@
+-- Finished emails are purged after the retention period.
+CREATE INDEX IF NOT EXISTS idx_email_queue_created_at ON email_queue(created_at) WHERE status <> 'queued';
@@ -0,0 +1,17 @@
+-- Links sent before verification and reset links became signed tokens keep
+-- working until they expire, and the purge job removes them.
+CREATE TABLE IF NOT EXISTS verify_emails (
+  id UUID PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email VARCHAR(100) NOT NULL,
+  expired_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS forgot_password_emails (
+  id UUID PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email VARCHAR(100) NOT NULL,
+  expired_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+-- Processed webhook events and finished deliveries are purged after the
+-- retention period.
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_processed_at ON webhook_outbox(processed_at) WHERE processed_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at) WHERE status <> 'pending';
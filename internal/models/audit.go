@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditRegister               = "auth.register"
+	AuditLogin                  = "auth.login"
+	AuditLoginFailed            = "auth.login_failed"
+	AuditNewDevice              = "auth.new_device"
+	AuditLogout                 = "auth.logout"
+	AuditTokenRefresh           = "auth.token_refresh"
+	AuditOrgSwitch              = "auth.org_switch"
+	AuditOAuthLogin             = "auth.oauth_login"
+	AuditPasswordResetRequested = "auth.password_reset_requested"
+	AuditPasswordReset          = "auth.password_reset"
+	AuditEmailVerified          = "auth.email_verified"
+	AuditUserUpdate             = "user.update"
+	AuditUserDelete             = "user.delete"
+	AuditIdentityLink           = "user.identity_link"
+	AuditIdentityUnlink         = "user.identity_unlink"
+	AuditAdminUserCreate        = "admin.user_create"
+	AuditAdminPasswordReset     = "admin.password_reset"
+	AuditAdminPasswordSet       = "admin.password_set"
+	AuditAdminEmailVerified     = "admin.email_verified"
+	AuditAdminStatusChange      = "admin.status_change"
+	AuditAdminSessionsRevoked   = "admin.sessions_revoked"
+	AuditAdminUserDelete        = "admin.user_delete"
+	AuditAdminRoleAssign        = "admin.role_assign"
+	AuditAdminRoleRemove        = "admin.role_remove"
+	AuditAdminBootstrap         = "admin.bootstrap"
+	AuditAdminWebhookCreate     = "admin.webhook_create"
+	AuditAdminWebhookUpdate     = "admin.webhook_update"
+	AuditAdminWebhookDelete     = "admin.webhook_delete"
+	AuditAdminWebhookRedeliver  = "admin.webhook_redeliver"
+	AuditOrgMemberJoin          = "org.member_join"
+	AuditOrgMemberRoleChange    = "org.member_role_change"
+	AuditOrgMemberRemove        = "org.member_remove"
+	AuditImpersonationStart     = "impersonation.start"
+	AuditImpersonationStop      = "impersonation.stop"
+)
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	ActorID    *int            `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows the admin audit search. UserID matches events the
+// user performed or was the target of. Results are newest first; pass the
+// previous page's next_cursor as Cursor to continue.
+type AuditFilter struct {
+	UserID    int        `json:"user_id" form:"user_id" validate:"omitempty,min=1"`
+	Actions   []string   `json:"action" form:"action" validate:"omitempty,dive,max=100"`
+	IPAddress string     `json:"ip" form:"ip" validate:"omitempty,ip"`
+	From      *time.Time `json:"from" form:"from"`
+	To        *time.Time `json:"to" form:"to"`
+	Cursor    int64      `json:"cursor" form:"cursor" validate:"omitempty,min=1"`
+	Limit     int        `json:"limit" form:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// UserActivity is an audit event as shown to the user it concerns. The IP
+// address and user agent are only shown for the user's own actions.
+type UserActivity struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditChainReport is the result of walking the audit hash chain. Legacy
+// counts the events written before the chain existed.
+type AuditChainReport struct {
+	Checked  int64  `json:"checked"`
+	Legacy   int64  `json:"legacy"`
+	LastID   int64  `json:"last_id"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
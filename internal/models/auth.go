@@ -12,6 +12,16 @@ type LoginRegisterRequest struct {
 	Password string `json:"password" validate:"required,min=8,max=100"`
 }
 
+// ResetPasswordRequest takes a signed link token, or the id of a reset link
+// sent before links were signed.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required_without=ID,max=2048"`
+	ID       string `json:"id" validate:"omitempty,uuid"`
+	Password string `json:"password" validate:"required,min=8,max=100"`
+}
+
+// VerifyEmail is a verification link sent before links were signed. It is
+// honored until it expires.
 type VerifyEmail struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    int       `json:"user_id"`
@@ -20,6 +30,8 @@ type VerifyEmail struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ForgotPassword is a reset link sent before links were signed. It is
+// honored until it expires.
 type ForgotPassword struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    int       `json:"user_id"`
@@ -28,11 +40,6 @@ type ForgotPassword struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type ResetPasswordRequest struct {
-	ID       string `json:"id" validate:"required,uuid"`
-	Password string `json:"password" validate:"required,min=8,max=100"`
-}
-
 type TokenLog struct {
 	ID               uuid.UUID  `json:"id"`
 	UserID           int        `json:"user_id"`
@@ -44,3 +51,27 @@ type TokenLog struct {
 	IPAddress        string     `json:"ip_address"`
 	UserAgent        string     `json:"user_agent"`
 }
+
+// Actor is the user acting on behalf of the token's subject, carried in the
+// RFC 8693 act claim of impersonation tokens.
+type Actor struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+type ImpersonationSession struct {
+	ID        uuid.UUID  `json:"id"`
+	ActorID   *int       `json:"actor_id"`
+	TargetID  int        `json:"target_id"`
+	JTI       string     `json:"-"`
+	Reason    string     `json:"reason"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiredAt time.Time  `json:"expired_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
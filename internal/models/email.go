@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Email statuses. Emails that fail with a temporary error stay queued until
+// they are sent or run out of attempts; a permanent rejection by the mail
+// server marks them bounced.
+const (
+	EmailQueued  = "queued"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+	EmailBounced = "bounced"
+)
+
+// Email is a message in the outgoing queue. The bodies hold verification
+// and reset links, so they are never serialized.
+type Email struct {
+	ID            int64      `json:"id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	HTMLBody      string     `json:"-"`
+	TextBody      string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type EmailFilter struct {
+	Status string `form:"status" validate:"omitempty,oneof=queued sent failed bounced"`
+	To     string `form:"to" validate:"omitempty,max=255"`
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=200"`
+}
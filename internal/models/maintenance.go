@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PurgeReport counts the rows removed by a purge of expired records.
+type PurgeReport struct {
+	LinkNonces        int `json:"link_nonces"`
+	LegacyLinks       int `json:"legacy_links"`
+	TokenLogs         int `json:"token_logs"`
+	Invitations       int `json:"invitations"`
+	JobRuns           int `json:"job_runs"`
+	Emails            int `json:"emails"`
+	WebhookEvents     int `json:"webhook_events"`
+	WebhookDeliveries int `json:"webhook_deliveries"`
+}
+
+func (r PurgeReport) Total() int {
+	return r.LinkNonces + r.LegacyLinks + r.TokenLogs + r.Invitations + r.JobRuns + r.Emails + r.WebhookEvents + r.WebhookDeliveries
+}
+
+const (
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun is one run of a scheduled job. Result holds what the job reported,
+// such as the rows purged from each table.
+type JobRun struct {
+	ID           int64           `json:"id"`
+	Job          string          `json:"job"`
+	Instance     string          `json:"instance"`
+	Status       string          `json:"status"`
+	RowsAffected int64           `json:"rows_affected"`
+	Result       json.RawMessage `json:"result"`
+	Error        string          `json:"error,omitempty"`
+	StartedAt    time.Time       `json:"started_at"`
+	FinishedAt   time.Time       `json:"finished_at"`
+}
+
+// JobSummary adds up the recorded runs of a job.
+type JobSummary struct {
+	Job          string    `json:"job"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	RowsAffected int64     `json:"rows_affected"`
+	LastRunAt    time.Time `json:"last_run_at"`
+	LastStatus   string    `json:"last_status"`
+	LastError    string    `json:"last_error,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type ServiceAccount struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Scopes         []string  `json:"scopes"`
+	CreatedBy      *int      `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID               int        `json:"id"`
+	ServiceAccountID int        `json:"service_account_id"`
+	Prefix           string     `json:"prefix"`
+	AllowedIPs       []string   `json:"allowed_ips"`
+	RotatedFromID    *int       `json:"rotated_from_id,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP       string     `json:"last_used_ip,omitempty"`
+	UsageCount       int64      `json:"usage_count"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type APIKeyUsage struct {
+	Day      time.Time `json:"day"`
+	Requests int64     `json:"requests"`
+}
+
+// CreateServiceAccountRequest scopes are read or write, plus any permissions
+// the creator holds, as for personal access tokens.
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+	Scopes      []string `json:"scopes" validate:"required,min=1,dive,required,max=100"`
+}
+
+type CreateAPIKeyRequest struct {
+	AllowedIPs []string   `json:"allowed_ips" validate:"omitempty,dive,cidr|ip"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest sets how long the old key keeps working after the
+// new one is issued. It defaults to 24 hours.
+type RotateAPIKeyRequest struct {
+	OverlapHours *int `json:"overlap_hours" validate:"omitempty,min=0,max=168"`
+}
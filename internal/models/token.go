@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Principal types stored in the principal_type claim. Session and
+// impersonation tokens have no principal_type and count as PrincipalUser.
+const (
+	PrincipalUser           = "user"
+	PrincipalPAT            = "pat"
+	PrincipalServiceAccount = "service_account"
+)
+
+// Principal is whoever authenticated a request: a user or a service account.
+type Principal struct {
+	Type        string   `json:"type"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	OrgID       int      `json:"org_id,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+}
+
+// Token scopes. read allows safe requests and write allows every request;
+// a token can additionally be given any permission its owner holds.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
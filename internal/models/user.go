@@ -2,19 +2,57 @@ package models
 
 import "time"
 
+const (
+	UserStatusActive          = "active"
+	UserStatusSuspended       = "suspended"
+	UserStatusLocked          = "locked"
+	UserStatusPendingDeletion = "pending_deletion"
+)
+
 type User struct {
-	ID         int       `json:"id"`
-	OAuthID    string    `json:"oauth_id,omitempty"`
-	Username   string    `json:"username" validate:"omitempty,min=3,max=30"`
-	AvatarURL  string    `json:"avatar_url,omitempty"`
-	Email      string    `json:"email" validate:"required,email"`
-	Password   string    `json:"password,omitempty" validate:"required_without=OAuthID,min=8,max=100"`
-	IsVerified bool      `json:"is_verified"`
-	Provider   string    `json:"provider,omitempty"`
-	IPAddress  string    `json:"ip_address,omitempty"`
-	UserAgent  string    `json:"user_agent,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID              int        `json:"id"`
+	OAuthID         string     `json:"oauth_id,omitempty"`
+	Username        string     `json:"username" validate:"omitempty,min=3,max=30"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	Email           string     `json:"email" validate:"required,email"`
+	Password        string     `json:"password,omitempty" validate:"required_without=OAuthID,min=8,max=100"`
+	IsVerified      bool       `json:"is_verified"`
+	Locale          string     `json:"locale,omitempty"`
+	Provider        string     `json:"provider,omitempty"`
+	Roles           []string   `json:"roles,omitempty"`
+	Permissions     []string   `json:"permissions,omitempty"`
+	Actor           *Actor     `json:"actor,omitempty"`
+	ActiveOrgID     int        `json:"active_org_id,omitempty"`
+	IPAddress       string     `json:"ip_address,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	Status          string     `json:"status,omitempty"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// UpdateUserStatusRequest sets an account status. A non-active status with
+// ExpiresAt lifts itself once that time has passed.
+type UpdateUserStatusRequest struct {
+	Status    string     `json:"status" validate:"required,oneof=active suspended locked pending_deletion"`
+	Reason    string     `json:"reason" validate:"omitempty,max=500"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateUserRequest creates an account on behalf of an operator. Accounts
+// that are not created verified are sent a verification email.
+type CreateUserRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Username string `json:"username" validate:"omitempty,min=3,max=30"`
+	Password string `json:"password" validate:"required,min=8,max=100"`
+	Verified bool   `json:"verified"`
+}
+
+// SetPasswordRequest is a password an operator sets for a user.
+type SetPasswordRequest struct {
+	Password string `json:"password" validate:"required,min=8,max=100"`
 }
 
 type UpdateUserRequest struct {
@@ -22,4 +60,47 @@ type UpdateUserRequest struct {
 	Username  string `json:"username" validate:"omitempty,min=3,max=30"`
 	AvatarURL string `json:"avatar_url,omitempty"`
 	Email     string `json:"email" validate:"required,email"`
+	Locale    string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+type UserIdentity struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	Email          string    `json:"email"`
+	LinkedAt       time.Time `json:"linked_at"`
+}
+
+type Role struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,max=50"`
+}
+
+// UserFilter narrows the admin user search. Nil booleans match both states.
+type UserFilter struct {
+	Query          string `json:"q" form:"q" validate:"omitempty,max=100"`
+	Provider       string `json:"provider" form:"provider"`
+	Role           string `json:"role" form:"role"`
+	Verified       *bool  `json:"verified" form:"verified"`
+	Status         string `json:"status" form:"status" validate:"omitempty,oneof=active suspended locked pending_deletion"`
+	IncludeDeleted bool   `json:"include_deleted" form:"include_deleted"`
+	Limit          int    `json:"limit" form:"limit" validate:"omitempty,min=1,max=100"`
+	Offset         int    `json:"offset" form:"offset" validate:"omitempty,min=0"`
+}
+
+type AdminUserDetails struct {
+	User       *User           `json:"user"`
+	Roles      []string        `json:"roles"`
+	Identities []*UserIdentity `json:"identities"`
+	Sessions   []*TokenLog     `json:"sessions"`
+	// Impersonations are the support sessions currently signed in as the user.
+	Impersonations []*ImpersonationSession `json:"impersonations"`
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types. Endpoints subscribed to WebhookAllEvents receive
+// every type.
+const (
+	WebhookAllEvents         = "*"
+	WebhookUserRegistered    = "user.registered"
+	WebhookUserEmailVerified = "user.email_verified"
+	WebhookUserEmailChanged  = "user.email_changed"
+	WebhookUserDeleted       = "user.deleted"
+	WebhookUserSuspended     = "user.suspended"
+)
+
+// Webhook delivery statuses. Failed attempts stay pending until the
+// dispatcher gives up and dead-letters the delivery.
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliverySucceeded  = "succeeded"
+	WebhookDeliveryDeadLetter = "dead"
+)
+
+type WebhookEndpoint struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"-"`
+	CreatedBy   *int      `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookEvent is the JSON body posted to endpoints.
+type WebhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDelivery is one event sent to one endpoint. URL and Secret are
+// filled when the delivery is claimed for sending.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int             `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url" validate:"required,url,max=2048"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=* user.registered user.email_verified user.email_changed user.deleted user.suspended"`
+}
+
+type UpdateWebhookEndpointRequest struct {
+	Active *bool `json:"active" validate:"required"`
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/lib/pq"
+)
+
+// auditChainLockID is the advisory lock serializing appends to the audit
+// hash chain.
+const auditChainLockID = 7_105_312_001
+
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, event models.AuditEvent) error
+	GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]*models.AuditEvent, error)
+	SearchEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db DBTX
+}
+
+func NewAuditRepository(dbtx DBTX) AuditRepository {
+	return &auditRepository{db: dbtx}
+}
+
+// CreateEvent appends event to the hash chain. The chain is locked until the
+// surrounding transaction ends and every audited write waits on that lock, so
+// it must be called inside one as its last statement.
+func (r *auditRepository) CreateEvent(ctx context.Context, event models.AuditEvent) error {
+	metadata := event.Metadata
+	if len(metadata) == 0 {
+		metadata = []byte("{}")
+	}
+
+	// Hash the metadata as jsonb will return it, not as it was encoded.
+	var normalized string
+	if err := r.db.QueryRowContext(ctx, "SELECT $1::jsonb::text", []byte(metadata)).Scan(&normalized); err != nil {
+		return err
+	}
+	event.Metadata = []byte(normalized)
+
+	if _, err := r.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLockID); err != nil {
+		return err
+	}
+
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(hash, '') FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&event.PrevHash); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	hash, err := utils.HashAuditEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to hash audit event: %v", err)
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, action, target_type, target_id, ip_address, user_agent, request_id, metadata, prev_hash, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)`
+	if _, err := r.db.ExecContext(ctx, query, event.ActorID, event.Action, event.TargetType, event.TargetID, event.IPAddress, event.UserAgent,
+		event.RequestID, normalized, event.PrevHash, hash, event.CreatedAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+const auditEventColumns = `id, actor_id, action, target_type, target_id, ip_address, user_agent, request_id, metadata,
+	COALESCE(prev_hash, ''), COALESCE(hash, ''), created_at`
+
+// GetEventsAfter returns up to limit events with an id above afterID, in
+// chain order.
+func (r *auditRepository) GetEventsAfter(ctx context.Context, afterID int64, limit int) ([]*models.AuditEvent, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+auditEventColumns+" FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %v", err)
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+// SearchEvents returns one page of events matching filter, newest first,
+// starting below filter.Cursor when it is set.
+func (r *auditRepository) SearchEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		addCondition("(actor_id = $%[1]d OR (target_type = 'user' AND target_id = $%[1]d::text))", filter.UserID)
+	}
+	if len(filter.Actions) > 0 {
+		addCondition("action = ANY($%d)", pq.Array(filter.Actions))
+	}
+	if filter.IPAddress != "" {
+		addCondition("ip_address = $%d", filter.IPAddress)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", filter.From.UTC())
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", filter.To.UTC())
+	}
+	if filter.Cursor != 0 {
+		addCondition("id < $%d", filter.Cursor)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf("SELECT %s FROM audit_events %s ORDER BY id DESC LIMIT $%d", auditEventColumns, where, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %v", err)
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+func scanAuditEvents(rows *sql.Rows) ([]*models.AuditEvent, error) {
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event := new(models.AuditEvent)
+		var metadata []byte
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.TargetType, &event.TargetID, &event.IPAddress, &event.UserAgent,
+			&event.RequestID, &metadata, &event.PrevHash, &event.Hash, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %v", err)
+		}
+		event.Metadata = metadata
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -4,22 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/Jonathan0823/auth-go/internal/models"
 )
 
 type AuthRepository interface {
-	CreateVerifyEmail(ctx context.Context, verifyEmail models.VerifyEmail) error
-	GetVerifyEmailByID(ctx context.Context, id string) (models.VerifyEmail, error)
-	VerifyEmail(ctx context.Context, id string) error
-	CreateForgotPasswordEmail(ctx context.Context, data models.ForgotPassword) error
-	GetForgotPasswordByID(ctx context.Context, id string) (models.ForgotPassword, error)
-	DeleteForgotPasswordByID(ctx context.Context, id string) error
+	UseLinkNonce(ctx context.Context, nonce, purpose string, userID int, expiresAt time.Time) error
+	IsLinkNonceUsedSince(ctx context.Context, userID int, purpose string, since time.Time) (bool, error)
+	GetVerifyEmailByID(ctx context.Context, id string) (*models.VerifyEmail, error)
+	GetForgotPasswordByID(ctx context.Context, id string) (*models.ForgotPassword, error)
 	CreateTokenLog(ctx context.Context, tokenLog models.TokenLog) error
 	GetTokenLogByJTI(ctx context.Context, jti string) (models.TokenLog, error)
-	InvalidateTokenLog(ctx context.Context, oldJti, newJti string) error
+	InvalidateTokenLog(ctx context.Context, jti string) error
 	IsTokenLogInvalidated(ctx context.Context, jti string) (bool, error)
+	GetActiveTokenLogsByUserID(ctx context.Context, userID int) ([]*models.TokenLog, error)
+	InvalidateUserTokenLogs(ctx context.Context, userID int) (int, error)
+	IsKnownDevice(ctx context.Context, userID int, userAgent string) (bool, error)
+	CreateImpersonationSession(ctx context.Context, session models.ImpersonationSession) error
+	GetImpersonationSessionByJTI(ctx context.Context, jti string) (*models.ImpersonationSession, error)
+	GetActiveImpersonationSessionsByTargetID(ctx context.Context, targetID int) ([]*models.ImpersonationSession, error)
+	EndImpersonationSession(ctx context.Context, jti string) error
+	DeleteExpiredLinkNonces(ctx context.Context) (int, error)
+	DeleteExpiredLegacyLinks(ctx context.Context) (int, error)
+	DeleteExpiredTokenLogs(ctx context.Context, before time.Time) (int, error)
 }
 
 type authRepository struct {
@@ -32,54 +40,81 @@ func NewAuthRepository(dbtx DBTX) AuthRepository {
 	}
 }
 
-func (r *authRepository) CreateVerifyEmail(ctx context.Context, verifyEmail models.VerifyEmail) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO verify_emails (id, user_id, email, expired_at) VALUES ($1, $2, $3, $4)", verifyEmail.ID, verifyEmail.UserID, verifyEmail.Email, verifyEmail.ExpiredAt)
+// UseLinkNonce records that the link token with nonce was used. It returns
+// sql.ErrNoRows when the nonce was used before.
+func (r *authRepository) UseLinkNonce(ctx context.Context, nonce, purpose string, userID int, expiresAt time.Time) error {
+	query := `
+		INSERT INTO link_token_nonces (nonce, purpose, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (nonce) DO NOTHING`
+	res, err := r.db.ExecContext(ctx, query, nonce, purpose, userID, expiresAt)
 	if err != nil {
 		return err
 	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-func (r *authRepository) GetVerifyEmailByID(ctx context.Context, id string) (models.VerifyEmail, error) {
-	var verifyEmail models.VerifyEmail
-	err := r.db.QueryRowContext(ctx, "SELECT id, email, expired_at FROM verify_emails WHERE id = $1", id).Scan(&verifyEmail.ID, &verifyEmail.Email, &verifyEmail.ExpiredAt)
-	if err != nil {
-		return models.VerifyEmail{}, err
+// IsLinkNonceUsedSince reports whether the user spent a link token for
+// purpose at or after since.
+func (r *authRepository) IsLinkNonceUsedSince(ctx context.Context, userID int, purpose string, since time.Time) (bool, error) {
+	var used bool
+	query := "SELECT EXISTS(SELECT 1 FROM link_token_nonces WHERE user_id = $1 AND purpose = $2 AND used_at >= $3)"
+	if err := r.db.QueryRowContext(ctx, query, userID, purpose, since).Scan(&used); err != nil {
+		return false, err
 	}
-	return verifyEmail, nil
+	return used, nil
 }
 
-func (r *authRepository) VerifyEmail(ctx context.Context, id string) error {
-	if _, err := r.db.ExecContext(ctx, "UPDATE users SET is_verified = true WHERE email = (SELECT email FROM verify_emails WHERE id = $1)", id); err != nil {
-		return err
+func (r *authRepository) GetVerifyEmailByID(ctx context.Context, id string) (*models.VerifyEmail, error) {
+	verifyEmail := new(models.VerifyEmail)
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, email, expired_at, created_at FROM verify_emails WHERE id = $1", id).Scan(
+		&verifyEmail.ID, &verifyEmail.UserID, &verifyEmail.Email, &verifyEmail.ExpiredAt, &verifyEmail.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
+	return verifyEmail, nil
+}
 
-	if _, err := r.db.ExecContext(ctx, "DELETE FROM verify_emails WHERE id = $1", id); err != nil {
-		return err
+func (r *authRepository) GetForgotPasswordByID(ctx context.Context, id string) (*models.ForgotPassword, error) {
+	data := new(models.ForgotPassword)
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, email, expired_at, created_at FROM forgot_password_emails WHERE id = $1", id).Scan(
+		&data.ID, &data.UserID, &data.Email, &data.ExpiredAt, &data.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
-
-	return nil
+	return data, nil
 }
 
-func (r *authRepository) CreateForgotPasswordEmail(ctx context.Context, data models.ForgotPassword) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO forgot_password_emails (id, email, expired_at) VALUES ($1, $2, $3)", data.ID, data.Email, data.ExpiredAt)
+func (r *authRepository) CreateTokenLog(ctx context.Context, tokenLog models.TokenLog) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO token_log (id, user_id, jti, refreshed_from_jti, invalidated_at, expired_at, created_at, ip_address, user_agent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		tokenLog.ID, tokenLog.UserID, tokenLog.JTI, tokenLog.RefreshedFromJTI, tokenLog.InvalidatedAt, tokenLog.ExpiredAt, tokenLog.CreatedAt, tokenLog.IPAddress, tokenLog.UserAgent)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *authRepository) GetForgotPasswordByID(ctx context.Context, id string) (models.ForgotPassword, error) {
-	var data models.ForgotPassword
-	err := r.db.QueryRowContext(ctx, "SELECT id, email, expired_at FROM forgot_password_emails WHERE id = $1", id).Scan(&data.ID, &data.Email, &data.ExpiredAt)
+func (r *authRepository) GetTokenLogByJTI(ctx context.Context, jti string) (models.TokenLog, error) {
+	var tokenLog models.TokenLog
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, jti, refreshed_from_jti, invalidated_at, expired_at, created_at, ip_address, user_agent FROM token_log WHERE jti = $1", jti).Scan(
+		&tokenLog.ID, &tokenLog.UserID, &tokenLog.JTI, &tokenLog.RefreshedFromJTI, &tokenLog.InvalidatedAt, &tokenLog.ExpiredAt, &tokenLog.CreatedAt, &tokenLog.IPAddress, &tokenLog.UserAgent)
 	if err != nil {
-		return models.ForgotPassword{}, err
+		return models.TokenLog{}, err
 	}
-	return data, nil
+	return tokenLog, nil
 }
 
-func (r *authRepository) DeleteForgotPasswordByID(ctx context.Context, id string) error {
-	res, err := r.db.ExecContext(ctx, "DELETE FROM forgot_password_emails WHERE id = $1", id)
+func (r *authRepository) InvalidateTokenLog(ctx context.Context, jti string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE token_log SET invalidated_at = NOW() WHERE jti = $1 AND invalidated_at IS NULL", jti)
 	if err != nil {
 		return err
 	}
@@ -89,54 +124,165 @@ func (r *authRepository) DeleteForgotPasswordByID(ctx context.Context, id string
 	return nil
 }
 
-func (r *authRepository) CreateTokenLog(ctx context.Context, tokenLog models.TokenLog) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO token_log (id, user_id, jti, refreshed_from_jti, invalidated_at, expired_at, created_at, ip_address, user_agent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-		tokenLog.ID, tokenLog.UserID, tokenLog.JTI, tokenLog.RefreshedFromJTI, tokenLog.InvalidatedAt, tokenLog.ExpiredAt, tokenLog.CreatedAt, tokenLog.IPAddress, tokenLog.UserAgent)
+func (r *authRepository) IsTokenLogInvalidated(ctx context.Context, jti string) (bool, error) {
+	var invalidated bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM token_log WHERE jti = $1 AND invalidated_at IS NOT NULL)", jti).Scan(&invalidated)
+	if err != nil {
+		return false, err
+	}
+	return invalidated, nil
+}
+
+func (r *authRepository) GetActiveTokenLogsByUserID(ctx context.Context, userID int) ([]*models.TokenLog, error) {
+	var tokenLogs []*models.TokenLog
+	query := `
+		SELECT id, user_id, jti, refreshed_from_jti, invalidated_at, expired_at, created_at, ip_address, user_agent
+		FROM token_log
+		WHERE user_id = $1 AND invalidated_at IS NULL AND expired_at > NOW()
+		ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token logs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tokenLog := new(models.TokenLog)
+		if err := rows.Scan(&tokenLog.ID, &tokenLog.UserID, &tokenLog.JTI, &tokenLog.RefreshedFromJTI, &tokenLog.InvalidatedAt, &tokenLog.ExpiredAt, &tokenLog.CreatedAt, &tokenLog.IPAddress, &tokenLog.UserAgent); err != nil {
+			return nil, fmt.Errorf("failed to scan token log: %v", err)
+		}
+		tokenLogs = append(tokenLogs, tokenLog)
+	}
+
+	return tokenLogs, nil
+}
+
+// IsKnownDevice reports whether the user has signed in with userAgent before.
+// A user without any session yet has no unknown devices.
+func (r *authRepository) IsKnownDevice(ctx context.Context, userID int, userAgent string) (bool, error) {
+	var known bool
+	query := `
+		SELECT NOT EXISTS (SELECT 1 FROM token_log WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM token_log WHERE user_id = $1 AND user_agent = $2)`
+	if err := r.db.QueryRowContext(ctx, query, userID, userAgent).Scan(&known); err != nil {
+		return false, err
+	}
+	return known, nil
+}
+
+// InvalidateUserTokenLogs invalidates every live refresh token of a user and
+// returns how many were invalidated.
+func (r *authRepository) InvalidateUserTokenLogs(ctx context.Context, userID int) (int, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE token_log SET invalidated_at = NOW() WHERE user_id = $1 AND invalidated_at IS NULL AND expired_at > NOW()", userID)
 	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
+
+func (r *authRepository) CreateImpersonationSession(ctx context.Context, session models.ImpersonationSession) error {
+	query := `
+		INSERT INTO impersonation_sessions (id, actor_id, target_id, jti, reason, ip_address, user_agent, expired_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := r.db.ExecContext(ctx, query, session.ID, session.ActorID, session.TargetID, session.JTI, session.Reason, session.IPAddress, session.UserAgent, session.ExpiredAt); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *authRepository) GetTokenLogByJTI(ctx context.Context, jti string) (models.TokenLog, error) {
-	var tokenLog models.TokenLog
-	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, jti, refreshed_from_jti, invalidated_at, expired_at, created_at, ip_address, user_agent FROM token_log WHERE jti = $1", jti).Scan(
-		&tokenLog.ID, &tokenLog.UserID, &tokenLog.JTI, &tokenLog.RefreshedFromJTI, &tokenLog.InvalidatedAt, &tokenLog.ExpiredAt, &tokenLog.CreatedAt, &tokenLog.IPAddress, &tokenLog.UserAgent)
+func (r *authRepository) GetImpersonationSessionByJTI(ctx context.Context, jti string) (*models.ImpersonationSession, error) {
+	session := new(models.ImpersonationSession)
+	query := `
+		SELECT id, actor_id, target_id, jti, reason, ip_address, user_agent, started_at, expired_at, ended_at
+		FROM impersonation_sessions
+		WHERE jti = $1`
+	err := r.db.QueryRowContext(ctx, query, jti).Scan(&session.ID, &session.ActorID, &session.TargetID, &session.JTI, &session.Reason,
+		&session.IPAddress, &session.UserAgent, &session.StartedAt, &session.ExpiredAt, &session.EndedAt)
 	if err != nil {
-		return models.TokenLog{}, err
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return tokenLog, nil
+	return session, nil
 }
 
-func (r *authRepository) InvalidateTokenLog(ctx context.Context, oldJTI, newJTI string) error {
-	setClauses := []string{"invalidated_at = NOW()"}
-	args := []any{oldJTI}
-
-	if newJTI != "" {
-		setClauses = append(setClauses, fmt.Sprintf("refreshed_from_jti = $%d", len(args)+1))
-		args = append(args, newJTI)
+func (r *authRepository) GetActiveImpersonationSessionsByTargetID(ctx context.Context, targetID int) ([]*models.ImpersonationSession, error) {
+	var sessions []*models.ImpersonationSession
+	query := `
+		SELECT id, actor_id, target_id, jti, reason, ip_address, user_agent, started_at, expired_at, ended_at
+		FROM impersonation_sessions
+		WHERE target_id = $1 AND ended_at IS NULL AND expired_at > NOW()
+		ORDER BY started_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query impersonation sessions: %v", err)
 	}
+	defer rows.Close()
 
-	setClause := strings.Join(setClauses, ", ")
+	for rows.Next() {
+		session := new(models.ImpersonationSession)
+		if err := rows.Scan(&session.ID, &session.ActorID, &session.TargetID, &session.JTI, &session.Reason,
+			&session.IPAddress, &session.UserAgent, &session.StartedAt, &session.ExpiredAt, &session.EndedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan impersonation session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
 
-	query := fmt.Sprintf(`
-	UPDATE your_table
-	SET %s
-	WHERE jti = $1
-`, setClause)
+	return sessions, nil
+}
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+// EndImpersonationSession returns sql.ErrNoRows if the session has already
+// ended.
+func (r *authRepository) EndImpersonationSession(ctx context.Context, jti string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE impersonation_sessions SET ended_at = NOW() WHERE jti = $1 AND ended_at IS NULL", jti)
 	if err != nil {
 		return err
 	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-func (r *authRepository) IsTokenLogInvalidated(ctx context.Context, jti string) (bool, error) {
-	var invalidated bool
-	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM token_log WHERE jti = $1 AND invalidated_at IS NOT NULL)", jti).Scan(&invalidated)
+// DeleteExpiredLinkNonces removes the nonces of link tokens that have
+// expired. An expired token is rejected before its nonce is looked up, so
+// they are no longer needed.
+func (r *authRepository) DeleteExpiredLinkNonces(ctx context.Context) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM link_token_nonces WHERE expires_at < NOW()")
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	return invalidated, nil
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
+
+// DeleteExpiredLegacyLinks removes expired verification and reset links from
+// before links were signed.
+func (r *authRepository) DeleteExpiredLegacyLinks(ctx context.Context) (int, error) {
+	deleted := 0
+	for _, query := range []string{
+		"DELETE FROM verify_emails WHERE expired_at < NOW()",
+		"DELETE FROM forgot_password_emails WHERE expired_at < NOW()",
+	} {
+		res, err := r.db.ExecContext(ctx, query)
+		if err != nil {
+			return deleted, err
+		}
+		rowsAffected, _ := res.RowsAffected()
+		deleted += int(rowsAffected)
+	}
+	return deleted, nil
+}
+
+// DeleteExpiredTokenLogs removes refresh tokens that expired or were
+// invalidated before the given time.
+func (r *authRepository) DeleteExpiredTokenLogs(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM token_log WHERE expired_at < $1 OR invalidated_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
 }
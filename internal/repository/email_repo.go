@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+type EmailRepository interface {
+	CreateEmail(ctx context.Context, email models.Email) (int64, error)
+	ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error)
+	UpdateEmail(ctx context.Context, email models.Email) error
+	GetEmails(ctx context.Context, filter models.EmailFilter) ([]*models.Email, error)
+	RequeueEmail(ctx context.Context, id int64) error
+	DeleteFinishedEmails(ctx context.Context, before time.Time) (int, error)
+}
+
+type emailRepository struct {
+	db DBTX
+}
+
+func NewEmailRepository(dbtx DBTX) EmailRepository {
+	return &emailRepository{db: dbtx}
+}
+
+const emailColumns = "id, recipient, subject, html_body, text_body, status, attempts, next_attempt_at, last_attempt_at, last_error, sent_at, created_at"
+
+func scanEmail(row interface{ Scan(...any) error }) (*models.Email, error) {
+	email := new(models.Email)
+	err := row.Scan(&email.ID, &email.To, &email.Subject, &email.HTMLBody, &email.TextBody, &email.Status, &email.Attempts,
+		&email.NextAttemptAt, &email.LastAttemptAt, &email.LastError, &email.SentAt, &email.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func (r *emailRepository) CreateEmail(ctx context.Context, email models.Email) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO email_queue (recipient, subject, html_body, text_body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, email.To, email.Subject, email.HTMLBody, email.TextBody).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ClaimDueEmails leases up to limit queued emails whose next attempt is due,
+// so that other workers skip them until the lease runs out.
+func (r *emailRepository) ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error) {
+	var emails []*models.Email
+	query := `
+		WITH due AS (
+			SELECT id FROM email_queue
+			WHERE status = 'queued' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE email_queue SET next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE id IN (SELECT id FROM due)
+		RETURNING ` + emailColumns
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		email, err := scanEmail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan email: %v", err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+func (r *emailRepository) UpdateEmail(ctx context.Context, email models.Email) error {
+	query := `
+		UPDATE email_queue
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = $5, last_error = $6, sent_at = $7
+		WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, email.ID, email.Status, email.Attempts, email.NextAttemptAt,
+		email.LastAttemptAt, email.LastError, email.SentAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *emailRepository) GetEmails(ctx context.Context, filter models.EmailFilter) ([]*models.Email, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.To != "" {
+		addCondition("LOWER(recipient) = LOWER($%d)", filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var emails []*models.Email
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf("SELECT %s FROM email_queue %s ORDER BY id DESC LIMIT $%d", emailColumns, where, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query emails: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		email, err := scanEmail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan email: %v", err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+// RequeueEmail queues a failed or bounced email again with a fresh set of
+// attempts.
+func (r *emailRepository) RequeueEmail(ctx context.Context, id int64) error {
+	query := `
+		UPDATE email_queue SET status = 'queued', attempts = 0, next_attempt_at = NOW(), last_error = ''
+		WHERE id = $1 AND status IN ('failed', 'bounced')`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteFinishedEmails removes emails created before the given time that are
+// no longer queued, whether they were sent, failed or bounced. Their bodies
+// hold verification and reset links.
+func (r *emailRepository) DeleteFinishedEmails(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM email_queue WHERE status <> 'queued' AND created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
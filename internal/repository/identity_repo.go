@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, identity models.UserIdentity) error
+	GetIdentity(ctx context.Context, provider, providerUserID string) (*models.UserIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID int) ([]*models.UserIdentity, error)
+	DeleteIdentity(ctx context.Context, userID int, provider string) error
+	DeleteIdentitiesByUserID(ctx context.Context, userID int) (int, error)
+}
+
+type identityRepository struct {
+	db DBTX
+}
+
+func NewIdentityRepository(dbtx DBTX) IdentityRepository {
+	return &identityRepository{db: dbtx}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identity models.UserIdentity) error {
+	query := "INSERT INTO user_identities (user_id, provider, provider_user_id, email) VALUES ($1, $2, $3, $4)"
+	_, err := r.db.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.ProviderUserID, identity.Email)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *identityRepository) GetIdentity(ctx context.Context, provider, providerUserID string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	query := "SELECT id, user_id, provider, provider_user_id, COALESCE(email, ''), linked_at FROM user_identities WHERE provider = $1 AND provider_user_id = $2"
+	err := r.db.QueryRowContext(ctx, query, provider, providerUserID).Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.ProviderUserID, &identity.Email, &identity.LinkedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) GetIdentitiesByUserID(ctx context.Context, userID int) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	query := "SELECT id, user_id, provider, provider_user_id, COALESCE(email, ''), linked_at FROM user_identities WHERE user_id = $1 ORDER BY linked_at"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query identities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		identity := new(models.UserIdentity)
+		err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.ProviderUserID, &identity.Email, &identity.LinkedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %v", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+func (r *identityRepository) DeleteIdentity(ctx context.Context, userID int, provider string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_identities WHERE user_id = $1 AND provider = $2", userID, provider)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *identityRepository) DeleteIdentitiesByUserID(ctx context.Context, userID int) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_identities WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
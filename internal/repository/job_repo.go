@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+type JobRepository interface {
+	CreateJobRun(ctx context.Context, run models.JobRun) (int64, error)
+	GetJobSummaries(ctx context.Context) ([]*models.JobSummary, error)
+	GetJobRuns(ctx context.Context, job string, limit int) ([]*models.JobRun, error)
+	DeleteJobRuns(ctx context.Context, before time.Time) (int, error)
+}
+
+type jobRepository struct {
+	db DBTX
+}
+
+func NewJobRepository(dbtx DBTX) JobRepository {
+	return &jobRepository{db: dbtx}
+}
+
+func (r *jobRepository) CreateJobRun(ctx context.Context, run models.JobRun) (int64, error) {
+	result := run.Result
+	if len(result) == 0 {
+		result = []byte("{}")
+	}
+
+	var id int64
+	query := `
+		INSERT INTO job_runs (job, instance, status, rows_affected, result, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, run.Job, run.Instance, run.Status, run.RowsAffected, []byte(result),
+		run.Error, run.StartedAt, run.FinishedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetJobSummaries adds up the recorded runs of every job, together with the
+// outcome of its latest run.
+func (r *jobRepository) GetJobSummaries(ctx context.Context) ([]*models.JobSummary, error) {
+	var summaries []*models.JobSummary
+	query := `
+		SELECT totals.job, totals.runs, totals.failures, totals.rows_affected, latest.started_at, latest.status, latest.error
+		FROM (
+			SELECT job, COUNT(*) AS runs, COUNT(*) FILTER (WHERE status = 'failed') AS failures, SUM(rows_affected) AS rows_affected
+			FROM job_runs
+			GROUP BY job
+		) totals
+		JOIN (
+			SELECT DISTINCT ON (job) job, started_at, status, error
+			FROM job_runs
+			ORDER BY job, started_at DESC
+		) latest ON latest.job = totals.job
+		ORDER BY totals.job`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job summaries: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		summary := new(models.JobSummary)
+		if err := rows.Scan(&summary.Job, &summary.Runs, &summary.Failures, &summary.RowsAffected,
+			&summary.LastRunAt, &summary.LastStatus, &summary.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan job summary: %v", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+func (r *jobRepository) GetJobRuns(ctx context.Context, job string, limit int) ([]*models.JobRun, error) {
+	var runs []*models.JobRun
+	query := `
+		SELECT id, job, instance, status, rows_affected, result, error, started_at, finished_at
+		FROM job_runs
+		WHERE job = $1
+		ORDER BY started_at DESC
+		LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, job, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		run := new(models.JobRun)
+		if err := rows.Scan(&run.ID, &run.Job, &run.Instance, &run.Status, &run.RowsAffected, &run.Result,
+			&run.Error, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %v", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+func (r *jobRepository) DeleteJobRuns(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM job_runs WHERE started_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
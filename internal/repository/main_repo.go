@@ -10,12 +10,30 @@ type Repository interface {
 	Begin(ctx context.Context, opts *sql.TxOptions) (UOW, error)
 	Auth() AuthRepository
 	Users() UserRepository
+	Identities() IdentityRepository
+	Roles() RoleRepository
+	Organizations() OrganizationRepository
+	Audit() AuditRepository
+	Tokens() TokenRepository
+	ServiceAccounts() ServiceAccountRepository
+	Webhooks() WebhookRepository
+	Emails() EmailRepository
+	Jobs() JobRepository
 	WithTx(ctx context.Context, fn func(u UOW) error) error
 }
 
 type UOW interface {
 	Users() UserRepository
 	Auth() AuthRepository
+	Identities() IdentityRepository
+	Roles() RoleRepository
+	Organizations() OrganizationRepository
+	Audit() AuditRepository
+	Tokens() TokenRepository
+	ServiceAccounts() ServiceAccountRepository
+	Webhooks() WebhookRepository
+	Emails() EmailRepository
+	Jobs() JobRepository
 	Commit() error
 	Rollback() error
 }
@@ -28,8 +46,19 @@ type repository struct{ db *sql.DB }
 
 func NewRepository(db *sql.DB) Repository { return &repository{db: db} }
 
-func (r *repository) Auth() AuthRepository  { return NewAuthRepository(r.db) }
-func (r *repository) Users() UserRepository { return NewUserRepository(r.db) }
+func (r *repository) Auth() AuthRepository                  { return NewAuthRepository(r.db) }
+func (r *repository) Users() UserRepository                 { return NewUserRepository(r.db) }
+func (r *repository) Identities() IdentityRepository        { return NewIdentityRepository(r.db) }
+func (r *repository) Roles() RoleRepository                 { return NewRoleRepository(r.db) }
+func (r *repository) Organizations() OrganizationRepository { return NewOrganizationRepository(r.db) }
+func (r *repository) Audit() AuditRepository                { return NewAuditRepository(r.db) }
+func (r *repository) Tokens() TokenRepository               { return NewTokenRepository(r.db) }
+func (r *repository) Webhooks() WebhookRepository           { return NewWebhookRepository(r.db) }
+func (r *repository) Emails() EmailRepository               { return NewEmailRepository(r.db) }
+func (r *repository) Jobs() JobRepository                   { return NewJobRepository(r.db) }
+func (r *repository) ServiceAccounts() ServiceAccountRepository {
+	return NewServiceAccountRepository(r.db)
+}
 
 func (r *repository) Begin(ctx context.Context, opts *sql.TxOptions) (UOW, error) {
 	tx, err := r.db.BeginTx(ctx, opts)
@@ -39,10 +68,19 @@ func (r *repository) Begin(ctx context.Context, opts *sql.TxOptions) (UOW, error
 	return &uow{tx: tx}, nil
 }
 
-func (u *uow) Users() UserRepository { return NewUserRepository(u.tx) }
-func (u *uow) Auth() AuthRepository  { return NewAuthRepository(u.tx) }
-func (u *uow) Commit() error         { return u.tx.Commit() }
-func (u *uow) Rollback() error       { return u.tx.Rollback() }
+func (u *uow) Users() UserRepository                     { return NewUserRepository(u.tx) }
+func (u *uow) Auth() AuthRepository                      { return NewAuthRepository(u.tx) }
+func (u *uow) Identities() IdentityRepository            { return NewIdentityRepository(u.tx) }
+func (u *uow) Roles() RoleRepository                     { return NewRoleRepository(u.tx) }
+func (u *uow) Organizations() OrganizationRepository     { return NewOrganizationRepository(u.tx) }
+func (u *uow) Audit() AuditRepository                    { return NewAuditRepository(u.tx) }
+func (u *uow) Tokens() TokenRepository                   { return NewTokenRepository(u.tx) }
+func (u *uow) ServiceAccounts() ServiceAccountRepository { return NewServiceAccountRepository(u.tx) }
+func (u *uow) Webhooks() WebhookRepository               { return NewWebhookRepository(u.tx) }
+func (u *uow) Emails() EmailRepository                   { return NewEmailRepository(u.tx) }
+func (u *uow) Jobs() JobRepository                       { return NewJobRepository(u.tx) }
+func (u *uow) Commit() error                             { return u.tx.Commit() }
+func (u *uow) Rollback() error                           { return u.tx.Rollback() }
 
 func (r *repository) WithTx(ctx context.Context, fn func(u UOW) error) error {
 	u, err := r.Begin(ctx, nil)
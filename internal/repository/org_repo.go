@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org models.Organization) (int, error)
+	GetOrganizationByID(ctx context.Context, id int) (*models.Organization, error)
+	LockOrganization(ctx context.Context, id int) error
+	GetOrganizationsByUserID(ctx context.Context, userID int) ([]*models.Organization, error)
+	CreateMembership(ctx context.Context, orgID, userID int, role string) error
+	GetMembership(ctx context.Context, orgID, userID int) (*models.Membership, error)
+	GetMembers(ctx context.Context, orgID int) ([]*models.Membership, error)
+	UpdateMembershipRole(ctx context.Context, orgID, userID int, role string) error
+	DeleteMembership(ctx context.Context, orgID, userID int) error
+	CountMembersWithRole(ctx context.Context, orgID int, role string) (int, error)
+	CreateInvitation(ctx context.Context, invitation models.OrganizationInvitation) error
+	GetInvitationByID(ctx context.Context, id string) (*models.OrganizationInvitation, error)
+	AcceptInvitation(ctx context.Context, id string) error
+	DeleteExpiredInvitations(ctx context.Context, before time.Time) (int, error)
+}
+
+type organizationRepository struct {
+	db DBTX
+}
+
+func NewOrganizationRepository(dbtx DBTX) OrganizationRepository {
+	return &organizationRepository{db: dbtx}
+}
+
+func (r *organizationRepository) CreateOrganization(ctx context.Context, org models.Organization) (int, error) {
+	var id int
+	query := "INSERT INTO organizations (name, slug, created_by) VALUES ($1, $2, $3) RETURNING id"
+	if err := r.db.QueryRowContext(ctx, query, org.Name, org.Slug, org.CreatedBy).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *organizationRepository) GetOrganizationByID(ctx context.Context, id int) (*models.Organization, error) {
+	org := new(models.Organization)
+	query := "SELECT id, name, slug, created_by, created_at, updated_at FROM organizations WHERE id = $1"
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&org.ID, &org.Name, &org.Slug, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+// LockOrganization locks the organization row until the transaction ends,
+// serializing changes to its memberships.
+func (r *organizationRepository) LockOrganization(ctx context.Context, id int) error {
+	var locked int
+	return r.db.QueryRowContext(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+}
+
+func (r *organizationRepository) GetOrganizationsByUserID(ctx context.Context, userID int) ([]*models.Organization, error) {
+	var orgs []*models.Organization
+	query := `
+		SELECT organizations.id, organizations.name, organizations.slug, memberships.role,
+			organizations.created_by, organizations.created_at, organizations.updated_at
+		FROM memberships
+		JOIN organizations ON organizations.id = memberships.organization_id
+		WHERE memberships.user_id = $1
+		ORDER BY organizations.name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		org := new(models.Organization)
+		if err := rows.Scan(&org.ID, &org.Name, &org.Slug, &org.Role, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %v", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
+
+func (r *organizationRepository) CreateMembership(ctx context.Context, orgID, userID int, role string) error {
+	query := "INSERT INTO memberships (organization_id, user_id, role) VALUES ($1, $2, $3)"
+	if _, err := r.db.ExecContext(ctx, query, orgID, userID, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *organizationRepository) GetMembership(ctx context.Context, orgID, userID int) (*models.Membership, error) {
+	membership := new(models.Membership)
+	query := `
+		SELECT memberships.organization_id, memberships.user_id, users.username, users.email, memberships.role, memberships.created_at
+		FROM memberships
+		JOIN users ON users.id = memberships.user_id
+		WHERE memberships.organization_id = $1 AND memberships.user_id = $2`
+	err := r.db.QueryRowContext(ctx, query, orgID, userID).Scan(&membership.OrganizationID, &membership.UserID, &membership.Username, &membership.Email, &membership.Role, &membership.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return membership, nil
+}
+
+func (r *organizationRepository) GetMembers(ctx context.Context, orgID int) ([]*models.Membership, error) {
+	var members []*models.Membership
+	query := `
+		SELECT memberships.organization_id, memberships.user_id, users.username, users.email, memberships.role, memberships.created_at
+		FROM memberships
+		JOIN users ON users.id = memberships.user_id
+		WHERE memberships.organization_id = $1
+		ORDER BY memberships.created_at`
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		member := new(models.Membership)
+		if err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %v", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+func (r *organizationRepository) UpdateMembershipRole(ctx context.Context, orgID, userID int, role string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE memberships SET role = $3 WHERE organization_id = $1 AND user_id = $2", orgID, userID, role)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *organizationRepository) DeleteMembership(ctx context.Context, orgID, userID int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM memberships WHERE organization_id = $1 AND user_id = $2", orgID, userID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *organizationRepository) CountMembersWithRole(ctx context.Context, orgID int, role string) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM memberships WHERE organization_id = $1 AND role = $2"
+	if err := r.db.QueryRowContext(ctx, query, orgID, role).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation models.OrganizationInvitation) error {
+	query := "INSERT INTO organization_invitations (id, organization_id, email, role, invited_by, expired_at) VALUES ($1, $2, $3, $4, $5, $6)"
+	if _, err := r.db.ExecContext(ctx, query, invitation.ID, invitation.OrganizationID, invitation.Email, invitation.Role, invitation.InvitedBy, invitation.ExpiredAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *organizationRepository) GetInvitationByID(ctx context.Context, id string) (*models.OrganizationInvitation, error) {
+	invitation := new(models.OrganizationInvitation)
+	query := "SELECT id, organization_id, email, role, invited_by, accepted_at, expired_at, created_at FROM organization_invitations WHERE id = $1"
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&invitation.ID, &invitation.OrganizationID, &invitation.Email, &invitation.Role, &invitation.InvitedBy, &invitation.AcceptedAt, &invitation.ExpiredAt, &invitation.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// AcceptInvitation marks a pending invitation as accepted. It returns
+// sql.ErrNoRows if the invitation was already accepted.
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE organization_invitations SET accepted_at = NOW() WHERE id = $1 AND accepted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredInvitations removes invitations that expired before the given
+// time without being accepted.
+func (r *organizationRepository) DeleteExpiredInvitations(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM organization_invitations WHERE accepted_at IS NULL AND expired_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	GetAllRoles(ctx context.Context) ([]*models.Role, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	GetUserPermissions(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string, source string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+	CountUsersWithRole(ctx context.Context, role string) (int, error)
+	SyncUserRoles(ctx context.Context, userID int, source string, roles []string) error
+}
+
+type roleRepository struct {
+	db DBTX
+}
+
+func NewRoleRepository(dbtx DBTX) RoleRepository {
+	return &roleRepository{db: dbtx}
+}
+
+func (r *roleRepository) GetAllRoles(ctx context.Context) ([]*models.Role, error) {
+	var roles []*models.Role
+	query := `
+		SELECT roles.id, roles.name, COALESCE(roles.description, ''), roles.created_at,
+			COALESCE(array_agg(permissions.name ORDER BY permissions.name) FILTER (WHERE permissions.name IS NOT NULL), '{}')
+		FROM roles
+		LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		LEFT JOIN permissions ON permissions.id = role_permissions.permission_id
+		GROUP BY roles.id
+		ORDER BY roles.name`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		role := new(models.Role)
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, pq.Array(&role.Permissions)); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %v", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	var roles []string
+	query := `
+		SELECT roles.name
+		FROM user_roles
+		JOIN roles ON roles.id = user_roles.role_id
+		WHERE user_roles.user_id = $1
+		ORDER BY roles.name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %v", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	var permissions []string
+	query := `
+		SELECT DISTINCT permissions.name
+		FROM user_roles
+		JOIN role_permissions ON role_permissions.role_id = user_roles.role_id
+		JOIN permissions ON permissions.id = role_permissions.permission_id
+		WHERE user_roles.user_id = $1
+		ORDER BY permissions.name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user permissions: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan user permission: %v", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) AssignRole(ctx context.Context, userID int, role string, source string) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id, source)
+		SELECT $1, id, $3 FROM roles WHERE name = $2
+		ON CONFLICT (user_id, role_id) DO NOTHING`
+	res, err := r.db.ExecContext(ctx, query, userID, role, source)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM roles WHERE name = $1)", role).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
+func (r *roleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	query := "DELETE FROM user_roles WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)"
+	res, err := r.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *roleRepository) CountUsersWithRole(ctx context.Context, role string) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = $1"
+	if err := r.db.QueryRowContext(ctx, query, role).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SyncUserRoles replaces the roles a user got from source with roles. Roles
+// granted by other sources are left alone and unknown role names are ignored.
+// It deletes before it inserts, so call it on a UOW inside WithTx.
+func (r *roleRepository) SyncUserRoles(ctx context.Context, userID int, source string, roles []string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND source = $2", userID, source); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id, source)
+		SELECT $1, id, $2 FROM roles WHERE name = ANY($3)
+		ON CONFLICT (user_id, role_id) DO NOTHING`
+	if _, err := r.db.ExecContext(ctx, query, userID, source, pq.Array(roles)); err != nil {
+		return err
+	}
+	return nil
+}
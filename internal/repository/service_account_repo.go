@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/lib/pq"
+)
+
+type ServiceAccountRepository interface {
+	CreateServiceAccount(ctx context.Context, account models.ServiceAccount) (int, error)
+	GetServiceAccountsByOrgID(ctx context.Context, orgID int) ([]*models.ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, orgID, id int) (*models.ServiceAccount, error)
+	GetServiceAccountByID(ctx context.Context, id int) (*models.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, orgID, id int) error
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (int, error)
+	GetAPIKeys(ctx context.Context, accountID int) ([]*models.APIKey, error)
+	GetAPIKey(ctx context.Context, accountID, id int) (*models.APIKey, error)
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ExpireAPIKey(ctx context.Context, id int, at time.Time) error
+	RevokeAPIKey(ctx context.Context, accountID, id int) error
+	RecordAPIKeyUsage(ctx context.Context, id int, ip string) error
+	GetAPIKeyUsage(ctx context.Context, id int, since time.Time) ([]*models.APIKeyUsage, error)
+}
+
+type serviceAccountRepository struct {
+	db DBTX
+}
+
+func NewServiceAccountRepository(dbtx DBTX) ServiceAccountRepository {
+	return &serviceAccountRepository{db: dbtx}
+}
+
+const serviceAccountColumns = "id, organization_id, name, description, scopes, created_by, created_at"
+
+func scanServiceAccount(row interface{ Scan(...any) error }) (*models.ServiceAccount, error) {
+	account := new(models.ServiceAccount)
+	err := row.Scan(&account.ID, &account.OrganizationID, &account.Name, &account.Description, pq.Array(&account.Scopes), &account.CreatedBy, &account.CreatedAt)
+	return account, err
+}
+
+func (r *serviceAccountRepository) CreateServiceAccount(ctx context.Context, account models.ServiceAccount) (int, error) {
+	var id int
+	query := `
+		INSERT INTO service_accounts (organization_id, name, description, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, account.OrganizationID, account.Name, account.Description, pq.Array(account.Scopes), account.CreatedBy).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *serviceAccountRepository) GetServiceAccountsByOrgID(ctx context.Context, orgID int) ([]*models.ServiceAccount, error) {
+	var accounts []*models.ServiceAccount
+	rows, err := r.db.QueryContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE organization_id = $1 ORDER BY name", orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service accounts: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service account: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (r *serviceAccountRepository) GetServiceAccount(ctx context.Context, orgID, id int) (*models.ServiceAccount, error) {
+	account, err := scanServiceAccount(r.db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE id = $1 AND organization_id = $2", id, orgID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+func (r *serviceAccountRepository) GetServiceAccountByID(ctx context.Context, id int) (*models.ServiceAccount, error) {
+	account, err := scanServiceAccount(r.db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+func (r *serviceAccountRepository) DeleteServiceAccount(ctx context.Context, orgID, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM service_accounts WHERE id = $1 AND organization_id = $2", id, orgID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const apiKeyColumns = `id, service_account_id, key_prefix, allowed_ips, rotated_from_id, expires_at, revoked_at,
+	last_used_at, COALESCE(last_used_ip, ''), usage_count, created_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*models.APIKey, error) {
+	key := new(models.APIKey)
+	err := row.Scan(&key.ID, &key.ServiceAccountID, &key.Prefix, pq.Array(&key.AllowedIPs), &key.RotatedFromID, &key.ExpiresAt, &key.RevokedAt,
+		&key.LastUsedAt, &key.LastUsedIP, &key.UsageCount, &key.CreatedAt)
+	return key, err
+}
+
+func (r *serviceAccountRepository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (int, error) {
+	var id int
+	query := `
+		INSERT INTO api_keys (service_account_id, key_hash, key_prefix, allowed_ips, rotated_from_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, key.ServiceAccountID, keyHash, key.Prefix, pq.Array(key.AllowedIPs), key.RotatedFromID, key.ExpiresAt).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *serviceAccountRepository) GetAPIKeys(ctx context.Context, accountID int) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE service_account_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC"
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *serviceAccountRepository) GetAPIKey(ctx context.Context, accountID, id int) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1 AND service_account_id = $2", id, accountID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetActiveAPIKeyByHash returns nil when no unrevoked, unexpired key has the
+// hash.
+func (r *serviceAccountRepository) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())"
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// ExpireAPIKey moves a key's expiry forward to at, keeping an earlier expiry.
+func (r *serviceAccountRepository) ExpireAPIKey(ctx context.Context, id int, at time.Time) error {
+	query := "UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $2), $2) WHERE id = $1"
+	if _, err := r.db.ExecContext(ctx, query, id, at); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) RevokeAPIKey(ctx context.Context, accountID, id int) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND service_account_id = $2 AND revoked_at IS NULL", id, accountID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) RecordAPIKeyUsage(ctx context.Context, id int, ip string) error {
+	if _, err := r.db.ExecContext(ctx, "UPDATE api_keys SET usage_count = usage_count + 1, last_used_at = NOW(), last_used_ip = $2 WHERE id = $1", id, ip); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_key_usage (api_key_id, day, request_count) VALUES ($1, CURRENT_DATE, 1)
+		ON CONFLICT (api_key_id, day) DO UPDATE SET request_count = api_key_usage.request_count + 1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) GetAPIKeyUsage(ctx context.Context, id int, since time.Time) ([]*models.APIKeyUsage, error) {
+	var usage []*models.APIKeyUsage
+	rows, err := r.db.QueryContext(ctx, "SELECT day, request_count FROM api_key_usage WHERE api_key_id = $1 AND day >= $2 ORDER BY day", id, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key usage: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		day := new(models.APIKeyUsage)
+		if err := rows.Scan(&day.Day, &day.Requests); err != nil {
+			return nil, fmt.Errorf("failed to scan api key usage: %v", err)
+		}
+		usage = append(usage, day)
+	}
+
+	return usage, nil
+}
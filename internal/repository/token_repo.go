@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/lib/pq"
+)
+
+type TokenRepository interface {
+	CreateToken(ctx context.Context, token models.PersonalAccessToken, tokenHash string) (int, error)
+	GetTokensByUserID(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error)
+	GetActiveTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, userID, id int) error
+	RevokeUserTokens(ctx context.Context, userID int) (int, error)
+	TouchToken(ctx context.Context, id int, ip string) error
+}
+
+type tokenRepository struct {
+	db DBTX
+}
+
+func NewTokenRepository(dbtx DBTX) TokenRepository {
+	return &tokenRepository{db: dbtx}
+}
+
+func (r *tokenRepository) CreateToken(ctx context.Context, token models.PersonalAccessToken, tokenHash string) (int, error) {
+	var id int
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, token.UserID, token.Name, tokenHash, token.Prefix, pq.Array(token.Scopes), token.ExpiresAt).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *tokenRepository) GetTokensByUserID(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	var tokens []*models.PersonalAccessToken
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, COALESCE(last_used_ip, ''), revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tokens: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		token := new(models.PersonalAccessToken)
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, pq.Array(&token.Scopes),
+			&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.RevokedAt, &token.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan token: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// GetActiveTokenByHash returns nil when no unrevoked, unexpired token has
+// the hash.
+func (r *tokenRepository) GetActiveTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	token := new(models.PersonalAccessToken)
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, COALESCE(last_used_ip, ''), revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, pq.Array(&token.Scopes),
+		&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *tokenRepository) RevokeToken(ctx context.Context, userID, id int) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeUserTokens(ctx context.Context, userID int) (int, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
+
+func (r *tokenRepository) TouchToken(ctx context.Context, id int, ip string) error {
+	if _, err := r.db.ExecContext(ctx, "UPDATE personal_access_tokens SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1", id, ip); err != nil {
+		return err
+	}
+	return nil
+}
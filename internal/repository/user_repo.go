@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Jonathan0823/auth-go/internal/models"
 )
@@ -12,11 +14,16 @@ import (
 type UserRepository interface {
 	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error)
-	CreateUser(ctx context.Context, user models.User) error
+	CreateUser(ctx context.Context, user models.User) (int, error)
 	GetAllUsers(ctx context.Context) ([]*models.User, error)
 	UpdateUser(ctx context.Context, user models.UpdateUserRequest) error
 	DeleteUser(ctx context.Context, id int) error
 	UpdateUserPassword(ctx context.Context, id int, newPassword string) error
+	SearchUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error)
+	GetUserDetails(ctx context.Context, id int) (*models.User, error)
+	SetUserVerified(ctx context.Context, id int) error
+	SetUserStatus(ctx context.Context, id int, status, reason string, expiresAt *time.Time) error
+	SoftDeleteUser(ctx context.Context, id int) error
 }
 
 type userRepository struct {
@@ -29,8 +36,12 @@ func NewUserRepository(dbtx DBTX) UserRepository {
 
 func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	var user models.User
-	query := "SELECT id, username, email, is_verified, updated_at, created_at FROM users WHERE id = $1"
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.IsVerified, &user.UpdatedAt, &user.CreatedAt)
+	query := `
+		SELECT id, username, email, is_verified, locale, status, COALESCE(status_reason, ''), status_expires_at, updated_at, created_at
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.IsVerified, &user.Locale,
+		&user.Status, &user.StatusReason, &user.StatusExpiresAt, &user.UpdatedAt, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -43,8 +54,8 @@ func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User,
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error) {
 	var user models.User
 	var scanFields []any
-	scanFields = append(scanFields, &user.ID, &user.Username, &user.Email, &user.IsVerified, &user.UpdatedAt, &user.CreatedAt)
-	selectFields := "id, username, email, is_verified, updated_at, created_at"
+	scanFields = append(scanFields, &user.ID, &user.Username, &user.Email, &user.IsVerified, &user.Locale, &user.Status, &user.StatusReason, &user.StatusExpiresAt, &user.UpdatedAt, &user.CreatedAt)
+	selectFields := "id, username, email, is_verified, locale, status, COALESCE(status_reason, ''), status_expires_at, updated_at, created_at"
 	if includePassword {
 		selectFields += ", password"
 		scanFields = append(scanFields, &user.Password)
@@ -53,7 +64,7 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string, inclu
 		SELECT 
 		%s
 		FROM users 
-		WHERE email = $1`, selectFields)
+		WHERE email = $1 AND deleted_at IS NULL`, selectFields)
 	err := r.db.QueryRowContext(ctx, query, email).Scan(scanFields...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -64,18 +75,22 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string, inclu
 	return &user, nil
 }
 
-func (r *userRepository) CreateUser(ctx context.Context, user models.User) error {
-	query := "INSERT INTO users (username, email, password) VALUES ($1, $2, $3)"
-	_, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.Password)
+func (r *userRepository) CreateUser(ctx context.Context, user models.User) (int, error) {
+	var id int
+	query := `
+		INSERT INTO users (username, email, password, avatar_url, is_verified, provider, locale)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, COALESCE(NULLIF($6, ''), 'local'), $7)
+		RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password, user.AvatarURL, user.IsVerified, user.Provider, user.Locale).Scan(&id)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return id, nil
 }
 
 func (r *userRepository) GetAllUsers(ctx context.Context) ([]*models.User, error) {
 	var users []*models.User
-	query := "SELECT id, username, email, is_verified, updated_at, created_at FROM users"
+	query := "SELECT id, username, email, is_verified, updated_at, created_at FROM users WHERE deleted_at IS NULL"
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %v", err)
@@ -95,8 +110,8 @@ func (r *userRepository) GetAllUsers(ctx context.Context) ([]*models.User, error
 }
 
 func (r *userRepository) UpdateUser(ctx context.Context, user models.UpdateUserRequest) error {
-	query := "UPDATE users SET username = $1, email = $2 WHERE id = $3"
-	_, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.ID)
+	query := "UPDATE users SET username = $1, email = $2, locale = COALESCE(NULLIF($4, ''), locale) WHERE id = $3"
+	_, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.ID, user.Locale)
 	if err != nil {
 		return err
 	}
@@ -120,3 +135,125 @@ func (r *userRepository) UpdateUserPassword(ctx context.Context, id int, newPass
 	}
 	return nil
 }
+
+// SearchUsers returns one page of users matching filter together with the
+// total number of matches.
+func (r *userRepository) SearchUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Query != "" {
+		addCondition("(users.email ILIKE $%[1]d OR users.username ILIKE $%[1]d)", "%"+filter.Query+"%")
+	}
+	if filter.Provider != "" {
+		addCondition("users.provider = $%d", filter.Provider)
+	}
+	if filter.Role != "" {
+		addCondition("EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE user_roles.user_id = users.id AND roles.name = $%d)", filter.Role)
+	}
+	if filter.Verified != nil {
+		addCondition("users.is_verified = $%d", *filter.Verified)
+	}
+	if filter.Status != "" {
+		addCondition("users.status = $%d", filter.Status)
+	}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "users.deleted_at IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %v", err)
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT users.id, users.username, users.email, COALESCE(users.avatar_url, ''), users.is_verified, COALESCE(users.provider, ''),
+			users.status, COALESCE(users.status_reason, ''), users.status_expires_at, users.deleted_at, users.updated_at, users.created_at
+		FROM users
+		%s
+		ORDER BY users.id
+		LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := new(models.User)
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.IsVerified, &user.Provider,
+			&user.Status, &user.StatusReason, &user.StatusExpiresAt, &user.DeletedAt, &user.UpdatedAt, &user.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+// GetUserDetails loads a user for operators, including soft deleted users.
+func (r *userRepository) GetUserDetails(ctx context.Context, id int) (*models.User, error) {
+	var user models.User
+	query := `
+		SELECT id, username, email, COALESCE(avatar_url, ''), is_verified, COALESCE(provider, ''),
+			status, COALESCE(status_reason, ''), status_expires_at, deleted_at, updated_at, created_at
+		FROM users
+		WHERE id = $1`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.IsVerified, &user.Provider,
+		&user.Status, &user.StatusReason, &user.StatusExpiresAt, &user.DeletedAt, &user.UpdatedAt, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) SetUserVerified(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET is_verified = true, updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *userRepository) SetUserStatus(ctx context.Context, id int, status, reason string, expiresAt *time.Time) error {
+	query := `
+		UPDATE users
+		SET status = $2, status_reason = NULLIF($3, ''), status_expires_at = $4, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, id, status, reason, expiresAt)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *userRepository) SoftDeleteUser(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) (int, error)
+	GetEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	GetEndpointByID(ctx context.Context, id int) (*models.WebhookEndpoint, error)
+	SetEndpointActive(ctx context.Context, id int, active bool) error
+	DeleteEndpoint(ctx context.Context, id int) error
+	CreateOutboxEvent(ctx context.Context, event models.WebhookEvent, payload []byte) error
+	FanOutEvents(ctx context.Context, limit int) (int, error)
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	CreateDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error)
+	GetDeliveries(ctx context.Context, endpointID, limit int) ([]*models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, endpointID int, id int64) (*models.WebhookDelivery, error)
+	DeleteProcessedOutboxEvents(ctx context.Context, before time.Time) (int, error)
+	DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int, error)
+}
+
+type webhookRepository struct {
+	db DBTX
+}
+
+func NewWebhookRepository(dbtx DBTX) WebhookRepository {
+	return &webhookRepository{db: dbtx}
+}
+
+const webhookEndpointColumns = "id, url, description, events, secret, active, created_by, created_at"
+
+func scanWebhookEndpoint(row interface{ Scan(...any) error }) (*models.WebhookEndpoint, error) {
+	endpoint := new(models.WebhookEndpoint)
+	err := row.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, pq.Array(&endpoint.Events), &endpoint.Secret, &endpoint.Active, &endpoint.CreatedBy, &endpoint.CreatedAt)
+	return endpoint, err
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) (int, error) {
+	var id int
+	query := `
+		INSERT INTO webhook_endpoints (url, description, events, secret, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, endpoint.URL, endpoint.Description, pq.Array(endpoint.Events), endpoint.Secret, endpoint.Active, endpoint.CreatedBy).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *webhookRepository) GetEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	var endpoints []*models.WebhookEndpoint
+	rows, err := r.db.QueryContext(ctx, "SELECT "+webhookEndpointColumns+" FROM webhook_endpoints ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook endpoints: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %v", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+func (r *webhookRepository) GetEndpointByID(ctx context.Context, id int) (*models.WebhookEndpoint, error) {
+	endpoint, err := scanWebhookEndpoint(r.db.QueryRowContext(ctx, "SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (r *webhookRepository) SetEndpointActive(ctx context.Context, id int, active bool) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE webhook_endpoints SET active = $2 WHERE id = $1", id, active)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_endpoints WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateOutboxEvent(ctx context.Context, event models.WebhookEvent, payload []byte) error {
+	query := "INSERT INTO webhook_outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)"
+	if _, err := r.db.ExecContext(ctx, query, event.ID, event.Type, payload, event.CreatedAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FanOutEvents turns up to limit unprocessed outbox events into one pending
+// delivery per active endpoint subscribed to them, and returns how many
+// events it processed. Concurrent callers skip each other's events.
+func (r *webhookRepository) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	query := `
+		WITH events AS (
+			SELECT id, event_id, event_type, payload
+			FROM webhook_outbox
+			WHERE processed_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+			SELECT webhook_endpoints.id, events.event_id, events.event_type, events.payload
+			FROM events
+			JOIN webhook_endpoints ON webhook_endpoints.active
+				AND (events.event_type = ANY(webhook_endpoints.events) OR '*' = ANY(webhook_endpoints.events))
+		)
+		UPDATE webhook_outbox SET processed_at = NOW()
+		WHERE id IN (SELECT id FROM events)`
+	res, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	processed, _ := res.RowsAffected()
+	return int(processed), nil
+}
+
+const webhookDeliveryColumns = `webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event_id, webhook_deliveries.event_type,
+	webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at,
+	webhook_deliveries.last_attempt_at, webhook_deliveries.response_status, webhook_deliveries.last_error, webhook_deliveries.created_at`
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }, extra ...any) (*models.WebhookDelivery, error) {
+	delivery := new(models.WebhookDelivery)
+	var payload []byte
+	dest := []any{&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.NextAttemptAt, &delivery.LastAttemptAt, &delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return delivery, nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries that are due and
+// pushes their next attempt back by lease, so that other dispatchers leave
+// them alone while they are being sent.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = NOW() + make_interval(secs => $2)
+			WHERE id IN (SELECT id FROM due)
+			RETURNING *
+		)
+		SELECT ` + webhookDeliveryColumns + `, webhook_endpoints.url, webhook_endpoints.secret
+		FROM claimed AS webhook_deliveries
+		JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id`
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanWebhookDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		delivery.URL = url
+		delivery.Secret = secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the outcome of a delivery attempt.
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = $5, response_status = $6, last_error = $7
+		WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastAttemptAt, delivery.ResponseStatus, delivery.LastError); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+	if err := r.db.QueryRowContext(ctx, query, delivery.EndpointID, delivery.EventID, delivery.EventType, []byte(delivery.Payload)).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *webhookRepository) GetDeliveries(ctx context.Context, endpointID, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY id DESC LIMIT $2"
+	rows, err := r.db.QueryContext(ctx, query, endpointID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, endpointID int, id int64) (*models.WebhookDelivery, error) {
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2"
+	delivery, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, query, id, endpointID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// DeleteProcessedOutboxEvents removes outbox events fanned out to their
+// deliveries before the given time.
+func (r *webhookRepository) DeleteProcessedOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_outbox WHERE processed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
+
+// DeleteFinishedDeliveries removes deliveries created before the given time
+// that are no longer pending, whether they succeeded or were dead-lettered.
+func (r *webhookRepository) DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return int(rowsAffected), nil
+}
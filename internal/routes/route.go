@@ -4,12 +4,13 @@ package routes
 import (
 	"github.com/Jonathan0823/auth-go/internal/handler"
 	"github.com/Jonathan0823/auth-go/internal/middleware"
+	"github.com/Jonathan0823/auth-go/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, mainHandler *handler.MainHandler) {
+func RegisterRoutes(r *gin.Engine, mainHandler *handler.MainHandler, svc service.Service) {
 	api := r.Group("/api")
-	api.Use(middleware.ErrorHandler())
+	api.Use(middleware.RequestID(), middleware.ErrorHandler())
 	auth := api.Group("/auth")
 	{
 		auth.POST("/register", mainHandler.Register)
@@ -31,17 +32,117 @@ func RegisterRoutes(r *gin.Engine, mainHandler *handler.MainHandler) {
 		{
 			provider.GET("/", mainHandler.OAuthLogin)
 			provider.GET("/callback", mainHandler.OAuthCallback)
+			provider.POST("/callback", mainHandler.OAuthCallback)
 		}
 	}
 
+	saml := api.Group("/saml/:connection")
+	{
+		saml.GET("/metadata", mainHandler.SAMLMetadata)
+		saml.GET("/login", mainHandler.SAMLLogin)
+		saml.POST("/acs", mainHandler.SAMLACS)
+	}
+
 	user := api.Group("/user")
-	user.Use(middleware.AuthMiddleware())
+	user.Use(middleware.AuthMiddleware(svc))
 	{
 		user.GET("/me", mainHandler.GetCurrentUser)
-		user.GET("/:id", mainHandler.GetUserByID)
-		user.GET("/get-all", mainHandler.GetAllUsers)
-		user.GET("/email", mainHandler.GetUserByEmail)
-		user.PATCH("/update", mainHandler.UpdateUser)
-		user.DELETE("/delete/:id", mainHandler.DeleteUser)
+		user.GET("/:id", middleware.RequirePermission("users:read"), mainHandler.GetUserByID)
+		user.GET("/get-all", middleware.RequirePermission("users:read"), mainHandler.GetAllUsers)
+		user.GET("/email", middleware.RequirePermission("users:read"), mainHandler.GetUserByEmail)
+		user.PATCH("/update", middleware.BlockImpersonation(), mainHandler.UpdateUser)
+		user.DELETE("/delete/:id", middleware.BlockImpersonation(), mainHandler.DeleteUser)
+		user.POST("/impersonation/stop", mainHandler.StopImpersonation)
+		user.GET("/activity", mainHandler.GetUserActivity)
+		tokens := user.Group("/tokens")
+		tokens.Use(middleware.RequireSession(), middleware.BlockImpersonation())
+		{
+			tokens.GET("", mainHandler.GetTokens)
+			tokens.POST("", mainHandler.CreateToken)
+			tokens.DELETE("/:id", mainHandler.RevokeToken)
+		}
+		identities := user.Group("/identities")
+		{
+			identities.GET("", mainHandler.GetIdentities)
+			identities.GET("/:provider/link", middleware.BlockImpersonation(), middleware.OAuthMiddleware(), mainHandler.LinkOAuth)
+			identities.DELETE("/:provider", middleware.BlockImpersonation(), mainHandler.UnlinkOAuth)
+		}
+	}
+
+	orgs := api.Group("/orgs")
+	orgs.Use(middleware.AuthMiddleware(svc))
+	{
+		orgs.POST("", mainHandler.CreateOrganization)
+		orgs.GET("", mainHandler.GetOrganizations)
+		orgs.POST("/invitations/accept", mainHandler.AcceptOrganizationInvitation)
+		orgs.POST("/:id/switch", middleware.BlockImpersonation(), mainHandler.SwitchOrganization)
+		orgs.POST("/:id/invitations", mainHandler.InviteOrganizationMember)
+		members := orgs.Group("/:id/members")
+		{
+			members.GET("", mainHandler.GetOrganizationMembers)
+			members.PATCH("/:userId", mainHandler.UpdateOrganizationMember)
+			members.DELETE("/:userId", mainHandler.RemoveOrganizationMember)
+		}
+		serviceAccounts := orgs.Group("/:id/service-accounts")
+		serviceAccounts.Use(middleware.RequireSession(), middleware.BlockImpersonation())
+		{
+			serviceAccounts.POST("", mainHandler.CreateServiceAccount)
+			serviceAccounts.GET("", mainHandler.GetServiceAccounts)
+			serviceAccounts.DELETE("/:accountId", mainHandler.DeleteServiceAccount)
+			serviceAccounts.POST("/:accountId/keys", mainHandler.CreateAPIKey)
+			serviceAccounts.GET("/:accountId/keys", mainHandler.GetAPIKeys)
+			serviceAccounts.POST("/:accountId/keys/:keyId/rotate", mainHandler.RotateAPIKey)
+			serviceAccounts.DELETE("/:accountId/keys/:keyId", mainHandler.RevokeAPIKey)
+			serviceAccounts.GET("/:accountId/keys/:keyId/usage", mainHandler.GetAPIKeyUsage)
+		}
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(svc), middleware.BlockImpersonation())
+	{
+		admin.GET("/roles", middleware.RequirePermission("roles:read"), mainHandler.GetRoles)
+		admin.GET("/audit", middleware.RequirePermission("audit:read"), mainHandler.SearchAuditEvents)
+		admin.GET("/audit/export", middleware.RequirePermission("audit:read"), mainHandler.ExportAuditEvents)
+		users := admin.Group("/users")
+		{
+			users.GET("", middleware.RequirePermission("users:read"), mainHandler.SearchUsers)
+			users.GET("/:id", middleware.RequirePermission("users:read"), mainHandler.GetUserDetails)
+			users.POST("/:id/password-reset", middleware.RequirePermission("users:write"), mainHandler.ForcePasswordReset)
+			users.POST("/:id/verify-email", middleware.RequirePermission("users:write"), mainHandler.AdminVerifyEmail)
+			users.PUT("/:id/status", middleware.RequireUser(), middleware.RequirePermission("users:write"), mainHandler.UpdateUserStatus)
+			users.DELETE("/:id/sessions", middleware.RequirePermission("users:write"), mainHandler.RevokeUserSessions)
+			users.DELETE("/:id", middleware.RequireUser(), middleware.RequirePermission("users:delete"), mainHandler.AdminDeleteUser)
+			users.POST("/:id/impersonate", middleware.RequireSession(), middleware.RequirePermission("users:impersonate"), mainHandler.StartImpersonation)
+		}
+		emails := admin.Group("/emails")
+		emails.Use(middleware.RequirePermission("emails:manage"))
+		{
+			emails.GET("", mainHandler.GetEmails)
+			emails.POST("/:id/retry", mainHandler.RetryEmail)
+		}
+		admin.GET("/email-templates/:name/preview", middleware.RequirePermission("emails:manage"), mainHandler.PreviewEmailTemplate)
+		jobs := admin.Group("/jobs")
+		jobs.Use(middleware.RequirePermission("jobs:read"))
+		{
+			jobs.GET("", mainHandler.GetJobs)
+			jobs.GET("/:name/runs", mainHandler.GetJobRuns)
+		}
+		webhooks := admin.Group("/webhooks")
+		webhooks.Use(middleware.RequirePermission("webhooks:manage"))
+		{
+			webhooks.POST("", middleware.RequireUser(), mainHandler.CreateWebhookEndpoint)
+			webhooks.GET("", mainHandler.GetWebhookEndpoints)
+			webhooks.PATCH("/:id", mainHandler.UpdateWebhookEndpoint)
+			webhooks.DELETE("/:id", mainHandler.DeleteWebhookEndpoint)
+			webhooks.GET("/:id/deliveries", mainHandler.GetWebhookDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryId/redeliver", mainHandler.RedeliverWebhook)
+		}
+		userRoles := admin.Group("/users/:id/roles")
+		userRoles.Use(middleware.RequirePermission("roles:manage"))
+		{
+			userRoles.GET("", mainHandler.GetUserRoles)
+			userRoles.POST("", mainHandler.AssignUserRole)
+			userRoles.DELETE("/:role", mainHandler.RemoveUserRole)
+		}
 	}
 }
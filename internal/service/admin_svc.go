@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"strconv"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultUserPageSize = 20
+
+type AdminService interface {
+	SearchUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error)
+	GetUserDetails(ctx context.Context, id int) (*models.AdminUserDetails, error)
+	CreateUser(ctx context.Context, req models.CreateUserRequest) (int, error)
+	ForcePasswordReset(ctx context.Context, id int) error
+	VerifyUserEmail(ctx context.Context, id int) error
+	SetUserPassword(ctx context.Context, id int, password string) error
+	SetUserStatus(ctx context.Context, actorID, id int, req models.UpdateUserStatusRequest) error
+	RevokeSessions(ctx context.Context, id int) (int, error)
+	DeleteUser(ctx context.Context, actorID, id int, hard bool) error
+}
+
+type adminService struct {
+	repo      repository.Repository
+	templates *mailer.Templates
+}
+
+func NewAdminService(repo repository.Repository, templates *mailer.Templates) AdminService {
+	return &adminService{
+		repo:      repo,
+		templates: templates,
+	}
+}
+
+func (s *adminService) SearchUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultUserPageSize
+	}
+
+	users, total, err := s.repo.Users().SearchUsers(ctx, filter)
+	if err != nil {
+		return nil, 0, errors.InternalServerError("failed to search users", err)
+	}
+	return users, total, nil
+}
+
+func (s *adminService) GetUserDetails(ctx context.Context, id int) (*models.AdminUserDetails, error) {
+	user, err := s.repo.Users().GetUserDetails(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user", err)
+	}
+	if user == nil {
+		return nil, errors.NotFound("user not found", nil)
+	}
+
+	roles, err := s.repo.Roles().GetUserRoles(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user roles", err)
+	}
+	identities, err := s.repo.Identities().GetIdentitiesByUserID(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get identities", err)
+	}
+	sessions, err := s.repo.Auth().GetActiveTokenLogsByUserID(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get sessions", err)
+	}
+	impersonations, err := s.repo.Auth().GetActiveImpersonationSessionsByTargetID(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get impersonation sessions", err)
+	}
+
+	details := &models.AdminUserDetails{
+		User:           user,
+		Roles:          roles,
+		Identities:     identities,
+		Sessions:       sessions,
+		Impersonations: impersonations,
+	}
+	if details.Roles == nil {
+		details.Roles = []string{}
+	}
+	if details.Identities == nil {
+		details.Identities = []*models.UserIdentity{}
+	}
+	if details.Sessions == nil {
+		details.Sessions = []*models.TokenLog{}
+	}
+	if details.Impersonations == nil {
+		details.Impersonations = []*models.ImpersonationSession{}
+	}
+	return details, nil
+}
+
+// CreateUser creates a local account and returns its id. Like Register, it
+// raises the user.registered webhook and queues a verification email unless
+// the account is created verified.
+func (s *adminService) CreateUser(ctx context.Context, req models.CreateUserRequest) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, errors.InternalServerError("failed to hash password", err)
+	}
+
+	user := models.User{
+		Email:      req.Email,
+		Username:   req.Username,
+		Password:   string(hashedPassword),
+		IsVerified: req.Verified,
+	}
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		id, err := u.Users().CreateUser(ctx, user)
+		if err != nil {
+			if utils.IsPGUniqueViolation(err) {
+				return errors.Conflict("email already exists", err)
+			}
+			return errors.InternalServerError("failed to create user", err)
+		}
+		user.ID = id
+
+		if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserRegistered, map[string]any{
+			"id":       id,
+			"email":    user.Email,
+			"username": user.Username,
+		}); err != nil {
+			return err
+		}
+		if !user.IsVerified {
+			if err := enqueueVerifyEmail(ctx, u.Emails(), s.templates, user); err != nil {
+				return err
+			}
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminUserCreate, id), map[string]any{
+			"email":    user.Email,
+			"verified": user.IsVerified,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// ForcePasswordReset clears the user's password, ends their sessions and
+// mails them a reset link. Until they reset it, only SSO logins work.
+func (s *adminService) ForcePasswordReset(ctx context.Context, id int) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		user, err := u.Users().GetUserByID(ctx, id)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by id", err)
+		}
+		if user == nil {
+			return errors.NotFound("user not found", nil)
+		}
+
+		if err := u.Users().UpdateUserPassword(ctx, id, ""); err != nil {
+			return errors.InternalServerError("failed to clear password", err)
+		}
+		if _, err := u.Auth().InvalidateUserTokenLogs(ctx, id); err != nil {
+			return errors.InternalServerError("failed to revoke sessions", err)
+		}
+		if err := enqueueResetPasswordEmail(ctx, u.Emails(), s.templates, *user); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminPasswordReset, id), nil)
+	})
+}
+
+func (s *adminService) VerifyUserEmail(ctx context.Context, id int) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Users().SetUserVerified(ctx, id); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("user not found", err)
+			}
+			return errors.InternalServerError("failed to verify email", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminEmailVerified, id), nil)
+	})
+}
+
+// SetUserPassword replaces the user's password and ends their sessions. The
+// user is told by email, as after a password reset.
+func (s *adminService) SetUserPassword(ctx context.Context, id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.InternalServerError("failed to hash password", err)
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		user, err := u.Users().GetUserByID(ctx, id)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by id", err)
+		}
+		if user == nil {
+			return errors.NotFound("user not found", nil)
+		}
+
+		if err := u.Users().UpdateUserPassword(ctx, id, string(hashedPassword)); err != nil {
+			return errors.InternalServerError("failed to update user password", err)
+		}
+		if _, err := u.Auth().InvalidateUserTokenLogs(ctx, id); err != nil {
+			return errors.InternalServerError("failed to revoke sessions", err)
+		}
+		if err := enqueueTemplateEmail(ctx, u.Emails(), s.templates, mailer.TemplatePasswordChanged, user.Email, user.Locale, map[string]any{
+			"Name": user.Username,
+			"Time": emailTime(time.Now()),
+			"URL":  emailURL(s.templates, "/forgot-password"),
+		}); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminPasswordSet, id), nil)
+	})
+}
+
+// SetUserStatus changes an account's status. Any status other than active
+// also revokes the user's sessions.
+func (s *adminService) SetUserStatus(ctx context.Context, actorID, id int, req models.UpdateUserStatusRequest) error {
+	if actorID == id {
+		return errors.BadRequest("you cannot change the status of your own account", nil)
+	}
+
+	active := req.Status == models.UserStatusActive
+	if active {
+		req.Reason = ""
+		req.ExpiresAt = nil
+	} else if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return errors.BadRequest("expires_at must be in the future", nil)
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Users().SetUserStatus(ctx, id, req.Status, req.Reason, req.ExpiresAt); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("user not found", err)
+			}
+			return errors.InternalServerError("failed to update user status", err)
+		}
+		if !active {
+			if _, err := u.Auth().InvalidateUserTokenLogs(ctx, id); err != nil {
+				return errors.InternalServerError("failed to revoke sessions", err)
+			}
+			if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserSuspended, map[string]any{
+				"id":         id,
+				"status":     req.Status,
+				"reason":     req.Reason,
+				"expires_at": req.ExpiresAt,
+			}); err != nil {
+				return err
+			}
+		}
+
+		event := adminEvent(models.AuditAdminStatusChange, id)
+		// actorID is 0 for CLI commands, which have no acting user.
+		if actorID != 0 {
+			event.ActorID = &actorID
+		}
+		return recordAuditEvent(ctx, u.Audit(), event, map[string]any{
+			"status":     req.Status,
+			"reason":     req.Reason,
+			"expires_at": req.ExpiresAt,
+		})
+	})
+}
+
+func (s *adminService) RevokeSessions(ctx context.Context, id int) (int, error) {
+	user, err := s.repo.Users().GetUserDetails(ctx, id)
+	if err != nil {
+		return 0, errors.InternalServerError("failed to get user", err)
+	}
+	if user == nil {
+		return 0, errors.NotFound("user not found", nil)
+	}
+
+	var revoked int
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		revoked, err = u.Auth().InvalidateUserTokenLogs(ctx, id)
+		if err != nil {
+			return errors.InternalServerError("failed to revoke sessions", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminSessionsRevoked, id), map[string]any{"revoked": revoked})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// DeleteUser soft deletes a user, keeping the row for investigations, or
+// removes it with everything that references it when hard is set.
+func (s *adminService) DeleteUser(ctx context.Context, actorID, id int, hard bool) error {
+	if actorID == id {
+		return errors.BadRequest("you cannot delete your own account from the admin API", nil)
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		user, err := u.Users().GetUserDetails(ctx, id)
+		if err != nil {
+			return errors.InternalServerError("failed to get user", err)
+		}
+		if user == nil {
+			return errors.NotFound("user not found", nil)
+		}
+
+		event := adminEvent(models.AuditAdminUserDelete, id)
+		event.ActorID = &actorID
+		metadata := map[string]any{"email": user.Email, "hard": hard}
+		if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserDeleted, map[string]any{"id": id, "email": user.Email, "hard": hard}); err != nil {
+			return err
+		}
+
+		if hard {
+			if err := u.Users().DeleteUser(ctx, id); err != nil {
+				return errors.InternalServerError("failed to delete user", err)
+			}
+			return recordAuditEvent(ctx, u.Audit(), event, metadata)
+		}
+
+		if err := u.Users().SoftDeleteUser(ctx, id); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.Conflict("user is already deleted", err)
+			}
+			return errors.InternalServerError("failed to delete user", err)
+		}
+		if _, err := u.Auth().InvalidateUserTokenLogs(ctx, id); err != nil {
+			return errors.InternalServerError("failed to revoke sessions", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), event, metadata)
+	})
+}
+
+// adminEvent describes an admin action on user id. The acting admin is taken
+// from the request unless the caller sets ActorID.
+func adminEvent(action string, id int) models.AuditEvent {
+	return models.AuditEvent{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(id),
+	}
+}
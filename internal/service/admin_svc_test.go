@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	goerror "errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+func newTestAdminService(t *testing.T) (AdminService, *memRepository) {
+	repo, templates := newTestRepository(t)
+	return NewAdminService(repo, templates), repo
+}
+
+func TestForcePasswordResetQueuesTheLinkWithTheReset(t *testing.T) {
+	svc, repo := newTestAdminService(t)
+
+	if err := svc.ForcePasswordReset(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if password := repo.store.users[1].Password; password != "" {
+		t.Errorf("password = %q, want it cleared", password)
+	}
+	if n := repo.store.sessions[1]; n != 0 {
+		t.Errorf("%d sessions left, want 0", n)
+	}
+	if len(repo.store.emails) != 1 || !strings.Contains(repo.store.emails[0].TextBody, "/reset-password?token=") {
+		t.Errorf("emails = %+v, want one reset link", repo.store.emails)
+	}
+	if want := []string{models.AuditAdminPasswordReset}; !slices.Equal(repo.store.audit, want) {
+		t.Errorf("audit = %v, want %v", repo.store.audit, want)
+	}
+}
+
+func TestForcePasswordResetKeepsThePasswordWhenTheEmailFails(t *testing.T) {
+	svc, repo := newTestAdminService(t)
+	repo.emailErr = goerror.New("queue unavailable")
+
+	wantStatus(t, svc.ForcePasswordReset(context.Background(), 1), http.StatusInternalServerError)
+	if password := repo.store.users[1].Password; password != "old-hash" {
+		t.Errorf("password = %q, want it kept", password)
+	}
+	if n := repo.store.sessions[1]; n != 3 {
+		t.Errorf("%d sessions left, want 3", n)
+	}
+}
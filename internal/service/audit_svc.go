@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+const (
+	// auditVerifyBatchSize is how many events VerifyChain loads at a time.
+	auditVerifyBatchSize = 1000
+	// auditExportBatchSize is how many events ExportEvents loads at a time.
+	auditExportBatchSize = 500
+	defaultAuditPageSize = 50
+	userActivityLimit    = 50
+)
+
+// userActivityActions are the events a user sees about their own account.
+var userActivityActions = []string{
+	models.AuditLogin,
+	models.AuditLoginFailed,
+	models.AuditNewDevice,
+	models.AuditLogout,
+	models.AuditOAuthLogin,
+	models.AuditPasswordResetRequested,
+	models.AuditPasswordReset,
+	models.AuditEmailVerified,
+	models.AuditUserUpdate,
+	models.AuditIdentityLink,
+	models.AuditIdentityUnlink,
+	models.AuditAdminPasswordReset,
+	models.AuditAdminStatusChange,
+	models.AuditAdminSessionsRevoked,
+}
+
+type AuditService interface {
+	SearchEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, int64, error)
+	ExportEvents(ctx context.Context, filter models.AuditFilter, fn func(event *models.AuditEvent) error) error
+	GetUserActivity(ctx context.Context, userID int) ([]*models.UserActivity, error)
+	VerifyChain(ctx context.Context) (*models.AuditChainReport, error)
+}
+
+type auditService struct {
+	repo repository.Repository
+}
+
+func NewAuditService(repo repository.Repository) AuditService {
+	return &auditService{
+		repo: repo,
+	}
+}
+
+// SearchEvents returns one page of events and the cursor of the next page,
+// which is 0 on the last page.
+func (s *auditService) SearchEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, int64, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+
+	events, err := s.repo.Audit().SearchEvents(ctx, filter)
+	if err != nil {
+		return nil, 0, errors.InternalServerError("failed to search audit events", err)
+	}
+
+	var next int64
+	if len(events) == filter.Limit {
+		next = events[len(events)-1].ID
+	}
+	return events, next, nil
+}
+
+// ExportEvents calls fn for every event matching filter, newest first,
+// loading them in batches. filter.Limit is ignored.
+func (s *auditService) ExportEvents(ctx context.Context, filter models.AuditFilter, fn func(event *models.AuditEvent) error) error {
+	filter.Limit = auditExportBatchSize
+	for {
+		events, err := s.repo.Audit().SearchEvents(ctx, filter)
+		if err != nil {
+			return errors.InternalServerError("failed to search audit events", err)
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		if len(events) < filter.Limit {
+			return nil
+		}
+		filter.Cursor = events[len(events)-1].ID
+	}
+}
+
+// GetUserActivity returns the user's recent security events. Network details
+// of actions taken by someone else, such as an admin, are left out.
+func (s *auditService) GetUserActivity(ctx context.Context, userID int) ([]*models.UserActivity, error) {
+	events, err := s.repo.Audit().SearchEvents(ctx, models.AuditFilter{
+		UserID:  userID,
+		Actions: userActivityActions,
+		Limit:   userActivityLimit,
+	})
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get activity", err)
+	}
+
+	activity := make([]*models.UserActivity, 0, len(events))
+	for _, event := range events {
+		item := &models.UserActivity{
+			ID:        event.ID,
+			Action:    event.Action,
+			CreatedAt: event.CreatedAt,
+		}
+		if event.ActorID == nil || *event.ActorID == userID {
+			item.IPAddress = event.IPAddress
+			item.UserAgent = event.UserAgent
+		}
+		activity = append(activity, item)
+	}
+	return activity, nil
+}
+
+// VerifyChain walks the audit log in order and stops at the first event
+// whose hash or link to the previous event does not match.
+func (s *auditService) VerifyChain(ctx context.Context) (*models.AuditChainReport, error) {
+	report := &models.AuditChainReport{}
+	prevHash := ""
+	chained := false
+
+	for {
+		events, err := s.repo.Audit().GetEventsAfter(ctx, report.LastID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, errors.InternalServerError("failed to get audit events", err)
+		}
+		if len(events) == 0 {
+			return report, nil
+		}
+
+		for _, event := range events {
+			if event.Hash == "" && !chained {
+				report.Legacy++
+				report.LastID = event.ID
+				continue
+			}
+			chained = true
+
+			reason := ""
+			switch {
+			case event.Hash == "":
+				reason = "event has no hash"
+			case event.PrevHash != prevHash:
+				reason = "previous hash does not match the previous event"
+			default:
+				hash, err := utils.HashAuditEvent(*event)
+				if err != nil {
+					return nil, errors.InternalServerError("failed to hash audit event", err)
+				}
+				if hash != event.Hash {
+					reason = "event was modified"
+				}
+			}
+			if reason != "" {
+				report.BrokenAt = &event.ID
+				report.Reason = reason
+				return report, nil
+			}
+
+			report.Checked++
+			report.LastID = event.ID
+			prevHash = event.Hash
+		}
+	}
+}
+
+// recordAuditEvent writes event with metadata through audit, which must
+// belong to a transaction. Pass the repository of the transaction making the
+// change so that both commit together, and make it the last write so the
+// audit chain lock is held briefly. Fields left empty are taken from the
+// request in ctx.
+func recordAuditEvent(ctx context.Context, audit repository.AuditRepository, event models.AuditEvent, metadata map[string]any) error {
+	request := utils.GetRequestMetadata(ctx)
+	if event.ActorID == nil {
+		event.ActorID = request.ActorID
+	}
+	if event.IPAddress == "" {
+		event.IPAddress = request.IPAddress
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = request.UserAgent
+	}
+	if event.RequestID == "" {
+		event.RequestID = request.RequestID
+	}
+	if event.ActorID == nil && request.ServiceAccountID != nil {
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		metadata["service_account_id"] = *request.ServiceAccountID
+	}
+
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return errors.InternalServerError("failed to encode audit metadata", err)
+		}
+		event.Metadata = data
+	}
+
+	if err := audit.CreateEvent(ctx, event); err != nil {
+		return errors.InternalServerError("failed to record audit event", err)
+	}
+	return nil
+}
+
+// logAuditEvent records event in a transaction of its own, for outcomes that
+// do not change anything else, such as failed logins.
+func logAuditEvent(ctx context.Context, repo repository.Repository, event models.AuditEvent, metadata map[string]any) error {
+	return repo.WithTx(ctx, func(u repository.UOW) error {
+		return recordAuditEvent(ctx, u.Audit(), event, metadata)
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	goerror "errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownUser is returned by an AuthBackend that does not know the user,
+// so Login can try the next backend.
+var ErrUnknownUser = goerror.New("unknown user")
+
+// AuthBackend verifies email and password credentials for Login.
+type AuthBackend interface {
+	Name() string
+	Authenticate(ctx context.Context, email, password string) (*models.User, error)
+}
+
+type localBackend struct {
+	repo repository.Repository
+}
+
+// NewLocalBackend checks passwords against the bcrypt hashes in users.
+func NewLocalBackend(repo repository.Repository) AuthBackend {
+	return &localBackend{repo: repo}
+}
+
+func (b *localBackend) Name() string { return "local" }
+
+func (b *localBackend) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	userFromDB, err := b.repo.Users().GetUserByEmail(ctx, email, true)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user by email", err)
+	}
+	if userFromDB == nil {
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(userFromDB.Password), []byte(password)); err != nil {
+		return nil, errors.Unauthorized("invalid credentials", err)
+	}
+	return userFromDB, nil
+}
+
+type ldapBackend struct {
+	repo repository.Repository
+	cfg  config.LDAP
+}
+
+// NewLDAPBackend authenticates with a search-then-bind against a directory.
+// Users are provisioned on their first login and their directory groups are
+// synced to roles on every login.
+func NewLDAPBackend(repo repository.Repository, cfg config.LDAP) AuthBackend {
+	return &ldapBackend{repo: repo, cfg: cfg}
+}
+
+func (b *ldapBackend) Name() string { return "ldap" }
+
+func (b *ldapBackend) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	if password == "" {
+		// An empty password would be an unauthenticated bind.
+		return nil, errors.Unauthorized("invalid credentials", nil)
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		log.Println("LDAP backend unavailable:", err)
+		return nil, ErrUnknownUser
+	}
+	defer conn.Close()
+
+	if b.cfg.BindDN != "" {
+		if err := conn.Bind(b.cfg.BindDN, b.cfg.BindPassword); err != nil {
+			log.Println("LDAP service bind failed:", err)
+			return nil, ErrUnknownUser
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		b.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(b.cfg.UserFilter, ldap.EscapeFilter(email)),
+		[]string{"dn", b.cfg.EmailAttribute, b.cfg.UsernameAttribute, b.cfg.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrUnknownUser
+		}
+		return nil, errors.InternalServerError("failed to search directory", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrUnknownUser
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, errors.Unauthorized("invalid credentials", err)
+	}
+
+	// The directory is authoritative for the addresses it stores, but not
+	// for whatever the user typed, so an entry without one cannot sign in.
+	data := models.User{
+		OAuthID:  entry.DN,
+		Provider: b.Name(),
+		Email:    entry.GetAttributeValue(b.cfg.EmailAttribute),
+		Username: entry.GetAttributeValue(b.cfg.UsernameAttribute),
+	}
+	if data.Email == "" {
+		return nil, errors.Forbidden("directory account has no email address", fmt.Errorf("%s has no %s attribute", entry.DN, b.cfg.EmailAttribute))
+	}
+
+	var roles []string
+	for _, group := range entry.GetAttributeValues(b.cfg.GroupAttribute) {
+		if role, ok := b.cfg.GroupRoles[strings.ToLower(group)]; ok {
+			roles = append(roles, role)
+		}
+	}
+
+	var user *models.User
+	err = b.repo.WithTx(ctx, func(u repository.UOW) error {
+		var metadata map[string]any
+		user, metadata, err = resolveProviderLogin(ctx, u, data, true)
+		if err != nil {
+			return err
+		}
+		if err := u.Roles().SyncUserRoles(ctx, user.ID, b.Name(), roles); err != nil {
+			return errors.InternalServerError("failed to sync user roles", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), oAuthLoginEvent(user.ID), metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (b *ldapBackend) dial() (*ldap.Conn, error) {
+	serverURL, err := url.Parse(b.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         serverURL.Hostname(),
+		InsecureSkipVerify: b.cfg.InsecureSkipVerify,
+	}
+
+	conn, err := ldap.DialURL(b.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	if b.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
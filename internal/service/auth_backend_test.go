@@ -0,0 +1,763 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// directoryEntry is one account of the LDAP stand-in.
+type directoryEntry struct {
+	password string
+	attrs    map[string][]string
+}
+
+// ldapStandIn is a minimal in-process LDAP server answering simple binds and
+// equality searches, enough for the search-then-bind of ldapBackend.
+type ldapStandIn struct {
+	listener net.Listener
+	entries  map[string]directoryEntry
+
+	mu    sync.Mutex
+	binds []string
+}
+
+func newLDAPStandIn(t *testing.T, entries map[string]directoryEntry) *ldapStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ldapStandIn{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStandIn) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if entry, ok := s.entries[dn]; ok && entry.password == password {
+				code = ldap.LDAPResultSuccess
+			}
+			conn.Write(ldapResponse(messageID, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				return
+			}
+			for dn, entry := range s.entries {
+				if entry.matches(filter) {
+					conn.Write(searchResultEntry(messageID, dn, entry.attrs).Bytes())
+				}
+			}
+			conn.Write(ldapResponse(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+// matches handles filters of the form (attr=value).
+func (e directoryEntry) matches(filter string) bool {
+	attr, value, found := strings.Cut(strings.Trim(filter, "()"), "=")
+	if !found {
+		return false
+	}
+	for _, v := range e.attrs[attr] {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func ldapResponse(messageID int64, tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return ldapMessage(messageID, result)
+}
+
+func searchResultEntry(messageID int64, dn string, attrs map[string][]string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range attrs {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	entry.AppendChild(attributes)
+	return ldapMessage(messageID, entry)
+}
+
+func ldapMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+// memStore is the state behind memRepository. WithTx works on a copy that
+// replaces it only when fn succeeds, like a rolled back transaction would.
+type memStore struct {
+	users      map[int]models.User
+	identities map[string]models.UserIdentity
+	roles      map[int][]string
+	// permissions are what GetUserPermissions reports for each user.
+	permissions map[int][]string
+	nonces      map[string]memNonce
+	resets      map[string]models.ForgotPassword
+	sessions    map[int]int
+	tokenLogs   map[string]models.TokenLog
+	emails      []models.Email
+	audit       []string
+	// memberships maps an organization and user to the user's role.
+	memberships map[[2]int]string
+	// locked holds the organizations locked by the transaction.
+	locked map[int]bool
+	// endpoints maps each webhook endpoint to whether it is active.
+	endpoints  map[int]bool
+	deliveries map[int64]models.WebhookDelivery
+
+	// audited is set once a transaction records an audit event. Audit
+	// events hold the chain lock until commit, so nothing may follow them.
+	audited bool
+}
+
+// write fails writes that follow an audit event in the same transaction.
+func (s *memStore) write() error {
+	if s.audited {
+		return goerror.New("write after the audit event")
+	}
+	return nil
+}
+
+type memNonce struct {
+	purpose string
+	userID  int
+	usedAt  time.Time
+}
+
+func (s *memStore) clone() *memStore {
+	c := &memStore{
+		users:       map[int]models.User{},
+		identities:  map[string]models.UserIdentity{},
+		roles:       map[int][]string{},
+		permissions: map[int][]string{},
+		nonces:      map[string]memNonce{},
+		resets:      map[string]models.ForgotPassword{},
+		sessions:    map[int]int{},
+		tokenLogs:   map[string]models.TokenLog{},
+		emails:      append([]models.Email(nil), s.emails...),
+		audit:       append([]string(nil), s.audit...),
+		memberships: map[[2]int]string{},
+		locked:      map[int]bool{},
+		endpoints:   map[int]bool{},
+		deliveries:  map[int64]models.WebhookDelivery{},
+	}
+	for k, v := range s.endpoints {
+		c.endpoints[k] = v
+	}
+	for k, v := range s.deliveries {
+		c.deliveries[k] = v
+	}
+	for k, v := range s.memberships {
+		c.memberships[k] = v
+	}
+	for k, v := range s.users {
+		c.users[k] = v
+	}
+	for k, v := range s.nonces {
+		c.nonces[k] = v
+	}
+	for k, v := range s.resets {
+		c.resets[k] = v
+	}
+	for k, v := range s.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range s.tokenLogs {
+		c.tokenLogs[k] = v
+	}
+	for k, v := range s.identities {
+		c.identities[k] = v
+	}
+	for k, v := range s.roles {
+		c.roles[k] = v
+	}
+	for k, v := range s.permissions {
+		c.permissions[k] = v
+	}
+	return c
+}
+
+type memRepository struct {
+	repository.Repository
+	store     *memStore
+	committed int
+	// syncErr fails SyncUserRoles, tokenLogErr fails CreateTokenLog and
+	// emailErr fails CreateEmail.
+	syncErr     error
+	tokenLogErr error
+	emailErr    error
+}
+
+func newMemRepository() *memRepository {
+	return &memRepository{store: (&memStore{}).clone()}
+}
+
+func (r *memRepository) WithTx(ctx context.Context, fn func(u repository.UOW) error) error {
+	staged := r.store.clone()
+	if err := fn(&memUOW{store: staged, repo: r}); err != nil {
+		return err
+	}
+	r.store = staged
+	r.committed++
+	return nil
+}
+
+type memUOW struct {
+	repository.UOW
+	store *memStore
+	repo  *memRepository
+}
+
+func (u *memUOW) Users() repository.UserRepository          { return memUsers{store: u.store} }
+func (u *memUOW) Identities() repository.IdentityRepository { return memIdentities{store: u.store} }
+func (u *memUOW) Roles() repository.RoleRepository {
+	return memRoles{store: u.store, err: u.repo.syncErr}
+}
+func (u *memUOW) Audit() repository.AuditRepository      { return memAudit{store: u.store} }
+func (u *memUOW) Webhooks() repository.WebhookRepository { return memWebhooks{store: u.store} }
+func (u *memUOW) Auth() repository.AuthRepository {
+	return memAuth{store: u.store, tokenLogErr: u.repo.tokenLogErr}
+}
+func (u *memUOW) Emails() repository.EmailRepository {
+	return memEmails{store: u.store, err: u.repo.emailErr}
+}
+func (u *memUOW) Organizations() repository.OrganizationRepository {
+	return memOrganizations{store: u.store}
+}
+
+func (r *memRepository) Users() repository.UserRepository   { return memUsers{store: r.store} }
+func (r *memRepository) Auth() repository.AuthRepository    { return memAuth{store: r.store} }
+func (r *memRepository) Roles() repository.RoleRepository   { return memRoles{store: r.store} }
+func (r *memRepository) Emails() repository.EmailRepository { return memEmails{store: r.store} }
+func (r *memRepository) Organizations() repository.OrganizationRepository {
+	return memOrganizations{store: r.store}
+}
+func (r *memRepository) Webhooks() repository.WebhookRepository {
+	return memWebhooks{store: r.store}
+}
+
+type memUsers struct {
+	repository.UserRepository
+	store *memStore
+}
+
+func (r memUsers) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	if user, ok := r.store.users[id]; ok {
+		return &user, nil
+	}
+	return nil, nil
+}
+
+func (r memUsers) GetUserByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error) {
+	for _, user := range r.store.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r memUsers) CreateUser(ctx context.Context, user models.User) (int, error) {
+	if err := r.store.write(); err != nil {
+		return 0, err
+	}
+	user.ID = len(r.store.users) + 1
+	r.store.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (r memUsers) UpdateUserPassword(ctx context.Context, id int, newPassword string) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	user := r.store.users[id]
+	user.Password = newPassword
+	r.store.users[id] = user
+	return nil
+}
+
+func (r memUsers) SetUserVerified(ctx context.Context, id int) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	user, ok := r.store.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.IsVerified = true
+	r.store.users[id] = user
+	return nil
+}
+
+type memIdentities struct {
+	repository.IdentityRepository
+	store *memStore
+}
+
+func (r memIdentities) GetIdentity(ctx context.Context, provider, providerUserID string) (*models.UserIdentity, error) {
+	if identity, ok := r.store.identities[provider+"|"+providerUserID]; ok {
+		return &identity, nil
+	}
+	return nil, nil
+}
+
+func (r memIdentities) CreateIdentity(ctx context.Context, identity models.UserIdentity) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	r.store.identities[identity.Provider+"|"+identity.ProviderUserID] = identity
+	return nil
+}
+
+func (r memIdentities) GetIdentitiesByUserID(ctx context.Context, userID int) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	for _, identity := range r.store.identities {
+		if identity.UserID == userID {
+			identities = append(identities, &identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r memIdentities) DeleteIdentity(ctx context.Context, userID int, provider string) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	for key, identity := range r.store.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			delete(r.store.identities, key)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+type memRoles struct {
+	repository.RoleRepository
+	store *memStore
+	err   error
+}
+
+func (r memRoles) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	return r.store.roles[userID], nil
+}
+
+func (r memRoles) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	return slices.Clone(r.store.permissions[userID]), nil
+}
+
+func (r memRoles) SyncUserRoles(ctx context.Context, userID int, source string, roles []string) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	if r.err != nil {
+		return r.err
+	}
+	r.store.roles[userID] = roles
+	return nil
+}
+
+type memAuth struct {
+	repository.AuthRepository
+	store       *memStore
+	tokenLogErr error
+}
+
+func (r memAuth) CreateTokenLog(ctx context.Context, tokenLog models.TokenLog) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	if r.tokenLogErr != nil {
+		return r.tokenLogErr
+	}
+	r.store.tokenLogs[tokenLog.JTI] = tokenLog
+	return nil
+}
+
+func (r memAuth) GetTokenLogByJTI(ctx context.Context, jti string) (models.TokenLog, error) {
+	tokenLog, ok := r.store.tokenLogs[jti]
+	if !ok {
+		return models.TokenLog{}, sql.ErrNoRows
+	}
+	return tokenLog, nil
+}
+
+func (r memAuth) InvalidateTokenLog(ctx context.Context, jti string) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	tokenLog, ok := r.store.tokenLogs[jti]
+	if !ok || tokenLog.InvalidatedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	tokenLog.InvalidatedAt = &now
+	r.store.tokenLogs[jti] = tokenLog
+	return nil
+}
+
+func (r memAuth) UseLinkNonce(ctx context.Context, nonce, purpose string, userID int, expiresAt time.Time) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	if _, ok := r.store.nonces[nonce]; ok {
+		return sql.ErrNoRows
+	}
+	r.store.nonces[nonce] = memNonce{purpose: purpose, userID: userID, usedAt: time.Now()}
+	return nil
+}
+
+func (r memAuth) IsLinkNonceUsedSince(ctx context.Context, userID int, purpose string, since time.Time) (bool, error) {
+	for _, nonce := range r.store.nonces {
+		if nonce.userID == userID && nonce.purpose == purpose && !nonce.usedAt.Before(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memAuth) GetForgotPasswordByID(ctx context.Context, id string) (*models.ForgotPassword, error) {
+	if data, ok := r.store.resets[id]; ok {
+		return &data, nil
+	}
+	return nil, nil
+}
+
+func (r memAuth) InvalidateUserTokenLogs(ctx context.Context, userID int) (int, error) {
+	if err := r.store.write(); err != nil {
+		return 0, err
+	}
+	n := r.store.sessions[userID]
+	delete(r.store.sessions, userID)
+	return n, nil
+}
+
+type memEmails struct {
+	repository.EmailRepository
+	store *memStore
+	err   error
+}
+
+func (r memEmails) CreateEmail(ctx context.Context, email models.Email) (int64, error) {
+	if err := r.store.write(); err != nil {
+		return 0, err
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	email.ID = int64(len(r.store.emails) + 1)
+	email.Status = models.EmailQueued
+	r.store.emails = append(r.store.emails, email)
+	return email.ID, nil
+}
+
+// ClaimDueEmails returns every queued email; the fake has no leases.
+func (r memEmails) ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error) {
+	var due []*models.Email
+	for _, email := range r.store.emails {
+		if email.Status == models.EmailQueued && len(due) < limit {
+			due = append(due, &email)
+		}
+	}
+	return due, nil
+}
+
+func (r memEmails) UpdateEmail(ctx context.Context, email models.Email) error {
+	r.store.emails[email.ID-1] = email
+	return nil
+}
+
+type memAudit struct {
+	repository.AuditRepository
+	store *memStore
+}
+
+// CreateEvent lets several events follow each other at the end of a
+// transaction.
+func (r memAudit) CreateEvent(ctx context.Context, event models.AuditEvent) error {
+	r.store.audited = true
+	r.store.audit = append(r.store.audit, event.Action)
+	return nil
+}
+
+func (r memAuth) IsKnownDevice(ctx context.Context, userID int, userAgent string) (bool, error) {
+	for _, tokenLog := range r.store.tokenLogs {
+		if tokenLog.UserID == userID && tokenLog.UserAgent == userAgent {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memOrganizations struct {
+	repository.OrganizationRepository
+	store *memStore
+}
+
+func (r memOrganizations) LockOrganization(ctx context.Context, id int) error {
+	r.store.locked[id] = true
+	return nil
+}
+
+func (r memOrganizations) GetMembership(ctx context.Context, orgID, userID int) (*models.Membership, error) {
+	role, ok := r.store.memberships[[2]int{orgID, userID}]
+	if !ok {
+		return nil, nil
+	}
+	return &models.Membership{OrganizationID: orgID, UserID: userID, Role: role}, nil
+}
+
+// CountMembersWithRole refuses to count members of an unlocked
+// organization, whose count a concurrent transaction could change.
+func (r memOrganizations) CountMembersWithRole(ctx context.Context, orgID int, role string) (int, error) {
+	if !r.store.locked[orgID] {
+		return 0, goerror.New("count without the organization lock")
+	}
+	count := 0
+	for key, memberRole := range r.store.memberships {
+		if key[0] == orgID && memberRole == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memOrganizations) UpdateMembershipRole(ctx context.Context, orgID, userID int, role string) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	r.store.memberships[[2]int{orgID, userID}] = role
+	return nil
+}
+
+func (r memOrganizations) DeleteMembership(ctx context.Context, orgID, userID int) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	delete(r.store.memberships, [2]int{orgID, userID})
+	return nil
+}
+
+type memWebhooks struct {
+	repository.WebhookRepository
+	store *memStore
+}
+
+func (memWebhooks) CreateOutboxEvent(ctx context.Context, event models.WebhookEvent, payload []byte) error {
+	return nil
+}
+
+func (r memWebhooks) SetEndpointActive(ctx context.Context, id int, active bool) error {
+	if err := r.store.write(); err != nil {
+		return err
+	}
+	if _, ok := r.store.endpoints[id]; !ok {
+		return sql.ErrNoRows
+	}
+	r.store.endpoints[id] = active
+	return nil
+}
+
+// FanOutEvents has nothing to do: tests add deliveries directly.
+func (memWebhooks) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (r memWebhooks) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	var due []*models.WebhookDelivery
+	for _, delivery := range r.store.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(time.Now()) && len(due) < limit {
+			due = append(due, &delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r memWebhooks) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	r.store.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (r memWebhooks) CreateDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error) {
+	if err := r.store.write(); err != nil {
+		return 0, err
+	}
+	delivery.ID = int64(len(r.store.deliveries) + 1)
+	r.store.deliveries[delivery.ID] = delivery
+	return delivery.ID, nil
+}
+
+func (r memWebhooks) GetDelivery(ctx context.Context, endpointID int, id int64) (*models.WebhookDelivery, error) {
+	delivery, ok := r.store.deliveries[id]
+	if !ok || delivery.EndpointID != endpointID {
+		return nil, nil
+	}
+	return &delivery, nil
+}
+
+const (
+	serviceDN = "cn=service,dc=example,dc=test"
+	aliceDN   = "uid=alice,ou=people,dc=example,dc=test"
+	adminsDN  = "cn=Admins,ou=groups,dc=example,dc=test"
+)
+
+func testDirectory() map[string]directoryEntry {
+	return map[string]directoryEntry{
+		serviceDN: {password: "service-secret"},
+		aliceDN: {password: "alice-secret", attrs: map[string][]string{
+			"mail":     {"alice@example.test"},
+			"uid":      {"alice"},
+			"memberOf": {adminsDN, "cn=unmapped,ou=groups,dc=example,dc=test"},
+		}},
+		// The uid looks like an email but the directory has no mail for it.
+		"uid=victim@example.test,ou=people,dc=example,dc=test": {password: "mallory-secret", attrs: map[string][]string{
+			"uid": {"victim@example.test"},
+		}},
+	}
+}
+
+func newTestLDAPBackend(t *testing.T, repo repository.Repository, filter string) (AuthBackend, *ldapStandIn) {
+	directory := newLDAPStandIn(t, testDirectory())
+	return NewLDAPBackend(repo, config.LDAP{
+		URL:               directory.url(),
+		BindDN:            serviceDN,
+		BindPassword:      "service-secret",
+		BaseDN:            "dc=example,dc=test",
+		UserFilter:        filter,
+		EmailAttribute:    "mail",
+		UsernameAttribute: "uid",
+		GroupAttribute:    "memberOf",
+		GroupRoles:        map[string]string{strings.ToLower(adminsDN): "admin"},
+	}), directory
+}
+
+func TestLDAPBackendProvisionsUserAndSyncsRoles(t *testing.T) {
+	repo := newMemRepository()
+	backend, directory := newTestLDAPBackend(t, repo, "(mail=%s)")
+
+	user, err := backend.Authenticate(context.Background(), "alice@example.test", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "alice@example.test" || user.Username != "alice" || !user.IsVerified {
+		t.Errorf("provisioned %+v", user)
+	}
+	if _, ok := repo.store.identities["ldap|"+aliceDN]; !ok {
+		t.Error("no ldap identity was linked")
+	}
+	if roles := repo.store.roles[user.ID]; len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("synced roles %v, want [admin]", roles)
+	}
+	if repo.committed != 1 {
+		t.Errorf("committed %d transactions, want 1", repo.committed)
+	}
+	if got := strings.Join(directory.binds, ";"); got != serviceDN+";"+aliceDN {
+		t.Errorf("binds %q, want the service account and then the user", got)
+	}
+}
+
+func TestLDAPBackendRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		login    string
+		password string
+		syncErr  error
+		wantCode int
+		unknown  bool
+	}{
+		{name: "wrong password", filter: "(mail=%s)", login: "alice@example.test", password: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "unknown user", filter: "(mail=%s)", login: "nobody@example.test", password: "x", unknown: true},
+		{name: "entry without mail attribute", filter: "(uid=%s)", login: "victim@example.test", password: "mallory-secret", wantCode: http.StatusForbidden},
+		{name: "role sync fails", filter: "(mail=%s)", login: "alice@example.test", password: "alice-secret", syncErr: goerror.New("connection reset"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepository()
+			repo.store.users[1] = models.User{ID: 1, Email: "victim@example.test", IsVerified: true, Status: models.UserStatusActive}
+			repo.syncErr = tt.syncErr
+			backend, _ := newTestLDAPBackend(t, repo, tt.filter)
+
+			_, err := backend.Authenticate(context.Background(), tt.login, tt.password)
+			if tt.unknown {
+				if !goerror.Is(err, ErrUnknownUser) {
+					t.Fatalf("err = %v, want ErrUnknownUser", err)
+				}
+			} else {
+				var appErr *errors.Error
+				if !goerror.As(err, &appErr) || appErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want status %d", err, tt.wantCode)
+				}
+			}
+
+			if len(repo.store.users) != 1 || len(repo.store.identities) != 0 || len(repo.store.roles) != 0 {
+				t.Errorf("store changed: %d users, %d identities, %d role sets", len(repo.store.users), len(repo.store.identities), len(repo.store.roles))
+			}
+		})
+	}
+}
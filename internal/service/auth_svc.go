@@ -5,11 +5,13 @@ import (
 	"context"
 	"database/sql"
 	goerror "errors"
-	"fmt"
-	"os"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
 	"github.com/Jonathan0823/auth-go/internal/models"
 	"github.com/Jonathan0823/auth-go/internal/repository"
 	"github.com/Jonathan0823/auth-go/utils"
@@ -17,25 +19,41 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	verifyEmailTTL   = time.Hour
+	resetPasswordTTL = 15 * time.Minute
+)
+
 type AuthService interface {
 	Register(ctx context.Context, user models.User) error
 	Login(ctx context.Context, user models.User) (string, string, error)
+	IssueTokens(ctx context.Context, user models.User) (string, string, error)
 	ForgotPassword(ctx context.Context, email string) error
 	CreateVerifyEmail(ctx context.Context, email string) error
-	VerifyEmail(ctx context.Context, id string) error
+	VerifyEmail(ctx context.Context, token string) error
 	ResetPassword(ctx context.Context, tokenStr string, newPassword string) error
 	RefreshTokens(ctx context.Context, refreshToken, ip, userAgent string) (string, string, error)
-	InvalidateJWTTokens(ctx context.Context, oldJTI, newJTI string) error
+	SwitchOrganization(ctx context.Context, refreshToken string, orgID int, ip, userAgent string) (string, string, error)
+	InvalidateJWTTokens(ctx context.Context, jti string) error
 	IsTokenLogInvalidated(ctx context.Context, jti string) (bool, error)
 }
 
 type authService struct {
-	repo repository.Repository
+	repo      repository.Repository
+	templates *mailer.Templates
+	backends  []AuthBackend
 }
 
-func NewAuthService(repo repository.Repository) AuthService {
+// NewAuthService uses the given login backends in order. Without backends
+// only the local password backend is used.
+func NewAuthService(repo repository.Repository, templates *mailer.Templates, backends ...AuthBackend) AuthService {
+	if len(backends) == 0 {
+		backends = []AuthBackend{NewLocalBackend(repo)}
+	}
 	return &authService{
-		repo: repo,
+		repo:      repo,
+		templates: templates,
+		backends:  backends,
 	}
 }
 
@@ -46,48 +64,126 @@ func (s *authService) Register(ctx context.Context, user models.User) error {
 	}
 
 	user.Password = string(hashedPassword)
-	if err := s.repo.Users().CreateUser(ctx, user); err != nil {
-		if utils.IsPGUniqueViolation(err) {
-			return errors.Conflict("email already exists", err)
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		id, err := u.Users().CreateUser(ctx, user)
+		if err != nil {
+			if utils.IsPGUniqueViolation(err) {
+				return errors.Conflict("email already exists", err)
+			}
+			return errors.InternalServerError("failed to create user", err)
 		}
-		return errors.InternalServerError("failed to create user", err)
-	}
-
-	if err := s.CreateVerifyEmail(ctx, user.Email); err != nil {
-		return errors.InternalServerError("failed to create verification email", err)
+		if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserRegistered, map[string]any{
+			"id":       id,
+			"email":    user.Email,
+			"username": user.Username,
+		}); err != nil {
+			return err
+		}
+		user.ID = id
+		if err := enqueueVerifyEmail(ctx, u.Emails(), s.templates, user); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &id,
+			Action:     models.AuditRegister,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(id),
+		}, nil)
+	})
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// Login tries each auth backend in order until one knows the user.
 func (s *authService) Login(ctx context.Context, user models.User) (string, string, error) {
-	userFromDB, err := s.repo.Users().GetUserByEmail(ctx, user.Email, true)
-	if err != nil {
-		return "", "", errors.InternalServerError("failed to get user by email", err)
+	var authenticated *models.User
+	for _, backend := range s.backends {
+		userFromBackend, err := backend.Authenticate(ctx, user.Email, user.Password)
+		if goerror.Is(err, ErrUnknownUser) {
+			continue
+		}
+		if err != nil {
+			s.recordLoginFailure(ctx, user, nil, err.Error())
+			return "", "", err
+		}
+		authenticated = userFromBackend
+		break
 	}
-	if userFromDB == nil {
+	if authenticated == nil {
+		s.recordLoginFailure(ctx, user, nil, "user not found")
 		return "", "", errors.NotFound("user not found", nil)
 	}
+	if err := ensureUserActive(authenticated); err != nil {
+		s.recordLoginFailure(ctx, user, &authenticated.ID, err.Error())
+		return "", "", err
+	}
+
+	authenticated.IPAddress = user.IPAddress
+	authenticated.UserAgent = user.UserAgent
+	return s.issueTokens(ctx, *authenticated, nil, models.AuditLogin, map[string]any{"method": "password"})
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(userFromDB.Password), []byte(user.Password)); err != nil {
-		return "", "", errors.Unauthorized("invalid credentials", err)
+// recordLoginFailure audits a failed login. The failure is returned to the
+// caller either way, so an audit error is only logged.
+func (s *authService) recordLoginFailure(ctx context.Context, user models.User, userID *int, reason string) {
+	event := models.AuditEvent{
+		Action:    models.AuditLoginFailed,
+		IPAddress: user.IPAddress,
+		UserAgent: user.UserAgent,
+	}
+	if userID != nil {
+		event.TargetType = "user"
+		event.TargetID = strconv.Itoa(*userID)
 	}
 
-	accessToken, _, err := utils.GenerateJWT(*userFromDB, "access")
+	if err := logAuditEvent(ctx, s.repo, event, map[string]any{"email": user.Email, "reason": reason}); err != nil {
+		log.Println("failed to audit login failure:", err)
+	}
+}
+
+// IssueTokens creates a new session for a user authenticated by an SSO
+// provider. The user's IPAddress and UserAgent are recorded on the session's
+// token log.
+func (s *authService) IssueTokens(ctx context.Context, user models.User) (string, string, error) {
+	return s.issueTokens(ctx, user, nil, models.AuditLogin, map[string]any{"method": "sso"})
+}
+
+// issueTokens loads the user's roles into the access token and logs the
+// refresh token. When refreshedFrom is set, the token it replaces is
+// invalidated in the same transaction and linked to the new one, so a failed
+// rotation leaves the old token usable.
+// The session is audited as action with metadata, and logins from a user
+// agent the user has not signed in with before also as a new device.
+func (s *authService) issueTokens(ctx context.Context, user models.User, refreshedFrom *string, action string, metadata map[string]any) (string, string, error) {
+	roles, err := s.repo.Roles().GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", "", errors.InternalServerError("failed to get user roles", err)
+	}
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return "", "", errors.InternalServerError("failed to get user permissions", err)
+	}
+	user.Roles = roles
+	user.Permissions = permissions
+
+	accessToken, _, err := utils.GenerateJWT(user, "access")
 	if err != nil {
 		return "", "", errors.InternalServerError("failed to generate access token", err)
 	}
 
-	refreshToken, jtiRefresh, err := utils.GenerateJWT(*userFromDB, "refresh")
+	refreshToken, jtiRefresh, err := utils.GenerateJWT(user, "refresh")
 	if err != nil {
 		return "", "", errors.InternalServerError("failed to generate refresh token", err)
 	}
 
 	tokenLog := models.TokenLog{
 		ID:               uuid.New(),
-		UserID:           userFromDB.ID,
+		UserID:           user.ID,
 		JTI:              jtiRefresh,
-		RefreshedFromJTI: nil,
+		RefreshedFromJTI: refreshedFrom,
 		InvalidatedAt:    nil,
 		ExpiredAt:        time.Now().Add(7 * 24 * time.Hour),
 		CreatedAt:        time.Now(),
@@ -95,8 +191,67 @@ func (s *authService) Login(ctx context.Context, user models.User) (string, stri
 		UserAgent:        user.UserAgent,
 	}
 
-	if err := s.repo.Auth().CreateTokenLog(ctx, tokenLog); err != nil {
-		return "", "", errors.InternalServerError("failed to create token log", err)
+	newDevice := false
+	if action == models.AuditLogin {
+		known, err := s.repo.Auth().IsKnownDevice(ctx, user.ID, user.UserAgent)
+		if err != nil {
+			return "", "", errors.InternalServerError("failed to check device", err)
+		}
+		newDevice = !known
+	}
+
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["session_id"] = tokenLog.ID
+
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		// Only the request that actually invalidates the old token may
+		// rotate it.
+		if refreshedFrom != nil {
+			if err := u.Auth().InvalidateTokenLog(ctx, *refreshedFrom); err != nil {
+				if goerror.Is(err, sql.ErrNoRows) {
+					return errors.Unauthorized("invalidated refresh token", err)
+				}
+				return errors.InternalServerError("failed to invalidate old tokens", err)
+			}
+		}
+		if err := u.Auth().CreateTokenLog(ctx, tokenLog); err != nil {
+			return errors.InternalServerError("failed to create token log", err)
+		}
+
+		event := models.AuditEvent{
+			ActorID:    &user.ID,
+			Action:     action,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(user.ID),
+			IPAddress:  user.IPAddress,
+			UserAgent:  user.UserAgent,
+		}
+		if newDevice {
+			if err := enqueueTemplateEmail(ctx, u.Emails(), s.templates, mailer.TemplateNewDevice, user.Email, user.Locale, map[string]any{
+				"Name":      user.Username,
+				"Time":      emailTime(tokenLog.CreatedAt),
+				"IPAddress": user.IPAddress,
+				"UserAgent": user.UserAgent,
+				"URL":       emailURL(s.templates, "/forgot-password"),
+			}); err != nil {
+				return err
+			}
+		}
+
+		// Audit events go last: they hold the audit chain lock until commit.
+		if err := recordAuditEvent(ctx, u.Audit(), event, metadata); err != nil {
+			return err
+		}
+		if !newDevice {
+			return nil
+		}
+		event.Action = models.AuditNewDevice
+		return recordAuditEvent(ctx, u.Audit(), event, map[string]any{"session_id": tokenLog.ID})
+	})
+	if err != nil {
+		return "", "", err
 	}
 
 	return accessToken, refreshToken, nil
@@ -111,46 +266,79 @@ func (s *authService) CreateVerifyEmail(ctx context.Context, email string) error
 		return errors.NotFound("user not found", nil)
 	}
 
-	verifyEmail := models.VerifyEmail{
-		ID:        uuid.New(),
-		UserID:    userFromDB.ID,
-		Email:     email,
-		ExpiredAt: time.Now().Add(1 * time.Hour),
-	}
-
-	if err := s.repo.Auth().CreateVerifyEmail(ctx, verifyEmail); err != nil {
-		return errors.InternalServerError("failed to create verification email", err)
-	}
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		return enqueueVerifyEmail(ctx, u.Emails(), s.templates, *userFromDB)
+	})
+}
 
-	if err := utils.SendEmail(email, "Verify Email", "Click here to verify your email"); err != nil {
-		return errors.InternalServerError("failed to send verification email", err)
+// enqueueVerifyEmail queues an email with a signed verification link for the
+// user.
+func enqueueVerifyEmail(ctx context.Context, emails repository.EmailRepository, templates *mailer.Templates, user models.User) error {
+	token, err := utils.GenerateLinkToken(utils.LinkPurposeVerifyEmail, user.ID, user.Email, verifyEmailTTL)
+	if err != nil {
+		return errors.InternalServerError("failed to sign verification link", err)
 	}
 
-	return nil
+	return enqueueTemplateEmail(ctx, emails, templates, mailer.TemplateVerifyEmail, user.Email, user.Locale, map[string]any{
+		"Name": user.Username,
+		"URL":  emailURL(templates, "/verify-email?token="+token),
+	})
 }
 
-func (s *authService) VerifyEmail(ctx context.Context, id string) error {
-	_, err := uuid.Parse(id)
+// enqueueResetPasswordEmail queues an email with a signed password reset
+// link for the user.
+func enqueueResetPasswordEmail(ctx context.Context, emails repository.EmailRepository, templates *mailer.Templates, user models.User) error {
+	token, err := utils.GenerateLinkToken(utils.LinkPurposeResetPassword, user.ID, user.Email, resetPasswordTTL)
 	if err != nil {
-		return errors.BadRequest("invalid token", err)
+		return errors.InternalServerError("failed to sign password reset link", err)
 	}
 
-	verifyEmail, err := s.repo.Auth().GetVerifyEmailByID(ctx, id)
-	if err != nil || verifyEmail.ID == uuid.Nil {
-		if goerror.Is(err, sql.ErrNoRows) {
-			return errors.NotFound("verification token not found", err)
-		}
-		return errors.InternalServerError("internal server error", err)
-	}
+	return enqueueTemplateEmail(ctx, emails, templates, mailer.TemplateResetPassword, user.Email, user.Locale, map[string]any{
+		"Name": user.Username,
+		"URL":  emailURL(templates, "/reset-password?token="+token),
+	})
+}
 
-	if time.Now().After(verifyEmail.ExpiredAt) {
-		return errors.BadRequest("token expired", err)
+// VerifyEmail accepts a signed verification token, or the id of a
+// verification link sent before links were signed.
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	var claims *utils.LinkClaims
+	if _, err := uuid.Parse(token); err == nil {
+		verifyEmail, err := s.repo.Auth().GetVerifyEmailByID(ctx, token)
+		if err != nil {
+			return errors.InternalServerError("failed to get verification link", err)
+		}
+		if verifyEmail == nil || time.Now().After(verifyEmail.ExpiredAt) {
+			return errors.BadRequest("invalid or expired token", nil)
+		}
+		claims = legacyLinkClaims(utils.LinkPurposeVerifyEmail, verifyEmail.ID, verifyEmail.UserID, verifyEmail.Email, verifyEmail.CreatedAt, verifyEmail.ExpiredAt)
+	} else {
+		claims, err = utils.ValidateLinkToken(token, utils.LinkPurposeVerifyEmail)
+		if err != nil {
+			return errors.BadRequest("invalid or expired token", err)
+		}
 	}
 
-	if err := s.repo.Auth().VerifyEmail(ctx, id); err != nil {
-		return errors.InternalServerError("failed to verify email", err)
-	}
-	return nil
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if _, err := useLinkToken(ctx, u, claims); err != nil {
+			return err
+		}
+		if err := u.Users().SetUserVerified(ctx, claims.UserID); err != nil {
+			return errors.InternalServerError("failed to verify email", err)
+		}
+		if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserEmailVerified, map[string]any{
+			"id":    claims.UserID,
+			"email": claims.Email,
+		}); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &claims.UserID,
+			Action:     models.AuditEmailVerified,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(claims.UserID),
+		}, map[string]any{"email": claims.Email})
+	})
 }
 
 func (s *authService) ForgotPassword(ctx context.Context, email string) error {
@@ -162,31 +350,37 @@ func (s *authService) ForgotPassword(ctx context.Context, email string) error {
 		return errors.NotFound("user not found", nil)
 	}
 
-	data := models.ForgotPassword{
-		ID:        uuid.New(),
-		UserID:    userFromDB.ID,
-		Email:     email,
-		ExpiredAt: time.Now().Add(15 * time.Minute),
-	}
-
-	if err := s.repo.Auth().CreateForgotPasswordEmail(ctx, data); err != nil {
-		return errors.InternalServerError("failed to create forgot password record", err)
-	}
-
-	baseURL := os.Getenv("BASE_URL")
-	if err := utils.SendEmail(email, "Password Reset", fmt.Sprintf(`
-      Click here to reset your password: <a href="%s/reset-password?id=%s">Reset Password</a>`,
-		baseURL, data.ID.String(),
-	)); err != nil {
-		return errors.InternalServerError("failed to send password reset email", err)
-	}
-
-	return nil
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := enqueueResetPasswordEmail(ctx, u.Emails(), s.templates, *userFromDB); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditPasswordResetRequested,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(userFromDB.ID),
+		}, nil)
+	})
 }
 
-func (s *authService) ResetPassword(ctx context.Context, id string, newPassword string) error {
-	if _, err := uuid.Parse(id); err != nil {
-		return errors.BadRequest("invalid token", err)
+// ResetPassword accepts a signed reset token, or the id of a reset link sent
+// before links were signed. Setting the password signs the user out
+// everywhere and voids every reset link issued before this one was used.
+func (s *authService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	var claims *utils.LinkClaims
+	if _, err := uuid.Parse(token); err == nil {
+		forgotPassword, err := s.repo.Auth().GetForgotPasswordByID(ctx, token)
+		if err != nil {
+			return errors.InternalServerError("failed to get password reset link", err)
+		}
+		if forgotPassword == nil || time.Now().After(forgotPassword.ExpiredAt) {
+			return errors.BadRequest("invalid or expired token", nil)
+		}
+		claims = legacyLinkClaims(utils.LinkPurposeResetPassword, forgotPassword.ID, forgotPassword.UserID, forgotPassword.Email, forgotPassword.CreatedAt, forgotPassword.ExpiredAt)
+	} else {
+		claims, err = utils.ValidateLinkToken(token, utils.LinkPurposeResetPassword)
+		if err != nil {
+			return errors.BadRequest("invalid or expired token", err)
+		}
 	}
 
 	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
@@ -195,73 +389,182 @@ func (s *authService) ResetPassword(ctx context.Context, id string, newPassword
 	}
 
 	return s.repo.WithTx(ctx, func(u repository.UOW) error {
-		forgotPassword, err := u.Auth().GetForgotPasswordByID(ctx, id)
-		if err != nil || forgotPassword.ID == uuid.Nil {
-			if goerror.Is(err, sql.ErrNoRows) {
-				return errors.NotFound("forgot password token not found", err)
-			}
-			return errors.InternalServerError("internal server error", err)
+		user, err := useLinkToken(ctx, u, claims)
+		if err != nil {
+			return err
 		}
 
-		if time.Now().After(forgotPassword.ExpiredAt) {
-			return errors.BadRequest("token expired", nil)
+		if err = u.Users().UpdateUserPassword(ctx, user.ID, string(hashedNewPassword)); err != nil {
+			return errors.InternalServerError("failed to update user password", err)
 		}
+		revoked, err := u.Auth().InvalidateUserTokenLogs(ctx, user.ID)
+		if err != nil {
+			return errors.InternalServerError("failed to revoke sessions", err)
+		}
+		if err := enqueueTemplateEmail(ctx, u.Emails(), s.templates, mailer.TemplatePasswordChanged, user.Email, user.Locale, map[string]any{
+			"Name": user.Username,
+			"Time": emailTime(time.Now()),
+			"URL":  emailURL(s.templates, "/forgot-password"),
+		}); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &user.ID,
+			Action:     models.AuditPasswordReset,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(user.ID),
+		}, map[string]any{"sessions_revoked": revoked})
+	})
+}
 
-		if err := u.Auth().DeleteForgotPasswordByID(ctx, id); err != nil {
-			return errors.InternalServerError("failed to delete forgot password record", err)
+// legacyLinkClaims describes a stored link from before links were signed, so
+// that it is spent like a signed one. Its id becomes the nonce.
+func legacyLinkClaims(purpose string, id uuid.UUID, userID int, email string, createdAt, expiredAt time.Time) *utils.LinkClaims {
+	return &utils.LinkClaims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		Nonce:     id.String(),
+		IssuedAt:  createdAt,
+		ExpiresAt: expiredAt,
+	}
+}
+
+// useLinkToken spends the nonce of a validated link token and returns its
+// user. Tokens are rejected once used, and when the account's email address
+// is no longer the one the link was sent to. A reset token is also rejected
+// once any reset link issued at or after it has been used.
+func useLinkToken(ctx context.Context, u repository.UOW, claims *utils.LinkClaims) (*models.User, error) {
+	user, err := u.Users().GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user by id", err)
+	}
+	if user == nil {
+		return nil, errors.NotFound("user not found", nil)
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, errors.BadRequest("token was issued for a different email address", nil)
+	}
+
+	if claims.Purpose == utils.LinkPurposeResetPassword {
+		superseded, err := u.Auth().IsLinkNonceUsedSince(ctx, claims.UserID, claims.Purpose, claims.IssuedAt)
+		if err != nil {
+			return nil, errors.InternalServerError("failed to use token", err)
 		}
+		if superseded {
+			return nil, errors.BadRequest("token has been superseded by a newer password reset", nil)
+		}
+	}
 
-		if err = u.Users().UpdateUserPassword(ctx, forgotPassword.UserID, string(hashedNewPassword)); err != nil {
-			return errors.InternalServerError("failed to update user password", err)
+	if err := u.Auth().UseLinkNonce(ctx, claims.Nonce, claims.Purpose, claims.UserID, claims.ExpiresAt); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return nil, errors.BadRequest("token has already been used", err)
 		}
-		return nil
-	})
+		return nil, errors.InternalServerError("failed to use token", err)
+	}
+	return user, nil
 }
 
+// RefreshTokens rotates a refresh token. The user is reloaded so the new
+// access token carries their current roles.
 func (s *authService) RefreshTokens(ctx context.Context, refreshToken, ip, userAgent string) (string, string, error) {
+	return s.rotateTokens(ctx, refreshToken, nil, ip, userAgent)
+}
+
+// SwitchOrganization rotates the refresh token like RefreshTokens but issues
+// the new pair for orgID. An orgID of 0 clears the active organization.
+func (s *authService) SwitchOrganization(ctx context.Context, refreshToken string, orgID int, ip, userAgent string) (string, string, error) {
+	return s.rotateTokens(ctx, refreshToken, &orgID, ip, userAgent)
+}
+
+// rotateTokens invalidates refreshToken and issues a new pair. The active
+// organization is carried over from the old token unless orgID is given.
+func (s *authService) rotateTokens(ctx context.Context, refreshToken string, orgID *int, ip, userAgent string) (string, string, error) {
 	claims, err := utils.ValidateJWT(refreshToken, "refresh")
 	if err != nil {
 		return "", "", errors.Unauthorized("invalid refresh token", err)
 	}
 
-	oldJTI := claims["jti"].(string)
-	isJWTInvalidated, err := s.IsTokenLogInvalidated(ctx, oldJTI)
-	if err != nil && isJWTInvalidated {
-		return "", "", errors.Unauthorized("invalidated refresh token", err)
+	oldJTI, _ := claims["jti"].(string)
+	tokenLog, err := s.repo.Auth().GetTokenLogByJTI(ctx, oldJTI)
+	if err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return "", "", errors.Unauthorized("invalid refresh token", err)
+		}
+		return "", "", errors.InternalServerError("failed to get token log", err)
 	}
-
-	user := models.User{
-		Username:  claims["username"].(string),
-		Email:     claims["email"].(string),
-		IPAddress: ip,
-		UserAgent: userAgent,
+	if tokenLog.InvalidatedAt != nil {
+		return "", "", errors.Unauthorized("invalidated refresh token", nil)
 	}
 
-	newAccessToken, _, err := utils.GenerateJWT(user, "access")
+	user, err := s.repo.Users().GetUserByID(ctx, tokenLog.UserID)
 	if err != nil {
-		return "", "", errors.InternalServerError("failed to generate access token", err)
+		return "", "", errors.InternalServerError("failed to get user by id", err)
 	}
-
-	newRefreshToken, newJTI, err := utils.GenerateJWT(user, "refresh")
-	if err != nil {
-		return "", "", errors.InternalServerError("failed to generate refresh token", err)
+	if user == nil {
+		return "", "", errors.Unauthorized("user not found", nil)
+	}
+	if err := ensureUserActive(user); err != nil {
+		return "", "", err
 	}
+	user.IPAddress = ip
+	user.UserAgent = userAgent
 
-	if err := s.InvalidateJWTTokens(ctx, oldJTI, newJTI); err != nil {
-		return "", "", errors.InternalServerError("failed to invalidate old tokens", err)
+	activeOrgID := 0
+	if id, ok := claims["org_id"].(float64); ok {
+		activeOrgID = int(id)
+	}
+	if orgID != nil {
+		activeOrgID = *orgID
+	}
+	if activeOrgID != 0 {
+		member, err := s.repo.Organizations().GetMembership(ctx, activeOrgID, user.ID)
+		if err != nil {
+			return "", "", errors.InternalServerError("failed to get membership", err)
+		}
+		if member == nil {
+			if orgID != nil {
+				return "", "", errors.NotFound("organization not found", nil)
+			}
+			// The user has left the organization since the token was issued.
+			activeOrgID = 0
+		}
 	}
+	user.ActiveOrgID = activeOrgID
 
-	return newAccessToken, newRefreshToken, nil
+	if orgID != nil {
+		return s.issueTokens(ctx, *user, &oldJTI, models.AuditOrgSwitch, map[string]any{"org_id": activeOrgID})
+	}
+	return s.issueTokens(ctx, *user, &oldJTI, models.AuditTokenRefresh, nil)
 }
 
-func (s *authService) InvalidateJWTTokens(ctx context.Context, oldJTI, newJTI string) error {
-	if oldJTI == "" || newJTI == "" {
-		return errors.BadRequest("oldJTI and newJTI cannot be empty", nil)
-	}
-	if err := s.repo.Auth().InvalidateTokenLog(ctx, oldJTI, newJTI); err != nil {
-		return errors.InternalServerError("failed to invalidate token log", err)
+func (s *authService) InvalidateJWTTokens(ctx context.Context, jti string) error {
+	if jti == "" {
+		return errors.BadRequest("jti cannot be empty", nil)
 	}
-	return nil
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		tokenLog, err := u.Auth().GetTokenLogByJTI(ctx, jti)
+		if err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return errors.InternalServerError("failed to get token log", err)
+		}
+
+		if err := u.Auth().InvalidateTokenLog(ctx, jti); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return errors.InternalServerError("failed to invalidate token log", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &tokenLog.UserID,
+			Action:     models.AuditLogout,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(tokenLog.UserID),
+		}, map[string]any{"session_id": tokenLog.ID})
+	})
 }
 
 func (s *authService) IsTokenLogInvalidated(ctx context.Context, jti string) (bool, error) {
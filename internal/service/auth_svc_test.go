@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	goerror "errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestRepository holds user 1, alice@example.test, with 3 sessions.
+func newTestRepository(t *testing.T) (*memRepository, *mailer.Templates) {
+	utils.UseSecrets(utils.Secrets{JWTAccess: "access", JWTRefresh: "refresh", LinkToken: "link"})
+	templates, err := mailer.NewTemplates(config.Mailer{BaseURL: "https://app.test", DefaultLocale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newMemRepository()
+	repo.store.users[1] = models.User{ID: 1, Email: "alice@example.test", Password: "old-hash", IsVerified: true, Status: models.UserStatusActive}
+	repo.store.sessions[1] = 3
+	return repo, templates
+}
+
+func newTestAuthService(t *testing.T) (AuthService, *memRepository) {
+	repo, templates := newTestRepository(t)
+	return NewAuthService(repo, templates), repo
+}
+
+func resetToken(t *testing.T) string {
+	token, err := utils.GenerateLinkToken(utils.LinkPurposeResetPassword, 1, "alice@example.test", resetPasswordTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	var appErr *errors.Error
+	if !goerror.As(err, &appErr) || appErr.Code != code {
+		t.Fatalf("err = %v, want status %d", err, code)
+	}
+}
+
+func TestResetPasswordRevokesSessionsAndOtherLinks(t *testing.T) {
+	svc, repo := newTestAuthService(t)
+	earlier, used := resetToken(t), resetToken(t)
+
+	if err := svc.ResetPassword(context.Background(), used, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(repo.store.users[1].Password), []byte("new-password")) != nil {
+		t.Error("password was not changed")
+	}
+	if n := repo.store.sessions[1]; n != 0 {
+		t.Errorf("%d sessions left, want 0", n)
+	}
+
+	wantStatus(t, svc.ResetPassword(context.Background(), used, "another-password"), http.StatusBadRequest)
+	wantStatus(t, svc.ResetPassword(context.Background(), earlier, "another-password"), http.StatusBadRequest)
+
+	// A link requested after the reset still works, even within the same
+	// second.
+	for nonce, n := range repo.store.nonces {
+		n.usedAt = time.Now().Truncate(time.Second)
+		repo.store.nonces[nonce] = n
+	}
+	if err := svc.ResetPassword(context.Background(), resetToken(t), "another-password"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResetPasswordAcceptsLegacyLinksUntilTheyExpire(t *testing.T) {
+	svc, repo := newTestAuthService(t)
+	live, expired := uuid.New(), uuid.New()
+	repo.store.resets[live.String()] = models.ForgotPassword{ID: live, UserID: 1, Email: "alice@example.test", CreatedAt: time.Now().Add(-time.Minute), ExpiredAt: time.Now().Add(10 * time.Minute)}
+	repo.store.resets[expired.String()] = models.ForgotPassword{ID: expired, UserID: 1, Email: "alice@example.test", CreatedAt: time.Now().Add(-time.Hour), ExpiredAt: time.Now().Add(-45 * time.Minute)}
+
+	wantStatus(t, svc.ResetPassword(context.Background(), expired.String(), "new-password"), http.StatusBadRequest)
+	wantStatus(t, svc.ResetPassword(context.Background(), uuid.NewString(), "new-password"), http.StatusBadRequest)
+
+	if err := svc.ResetPassword(context.Background(), live.String(), "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if n := repo.store.sessions[1]; n != 0 {
+		t.Errorf("%d sessions left, want 0", n)
+	}
+	wantStatus(t, svc.ResetPassword(context.Background(), live.String(), "another-password"), http.StatusBadRequest)
+}
+
+// refreshToken issues a refresh token for user 1 and logs it.
+func refreshToken(t *testing.T, repo *memRepository) string {
+	token, jti, err := utils.GenerateJWT(repo.store.users[1], "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.store.tokenLogs[jti] = models.TokenLog{UserID: 1, JTI: jti, ExpiredAt: time.Now().Add(time.Hour)}
+	return token
+}
+
+func TestRefreshTokensKeepsOldTokenWhenIssuingFails(t *testing.T) {
+	svc, repo := newTestAuthService(t)
+	token := refreshToken(t, repo)
+
+	repo.tokenLogErr = goerror.New("connection reset")
+	if _, _, err := svc.RefreshTokens(context.Background(), token, "203.0.113.7", "test"); err == nil {
+		t.Fatal("refresh succeeded while the token log could not be written")
+	}
+
+	repo.tokenLogErr = nil
+	_, rotated, err := svc.RefreshTokens(context.Background(), token, "203.0.113.7", "test")
+	if err != nil {
+		t.Fatalf("old refresh token no longer works: %v", err)
+	}
+	if _, _, err := svc.RefreshTokens(context.Background(), token, "203.0.113.7", "test"); err == nil {
+		t.Error("old refresh token still works after rotation")
+	}
+	if _, _, err := svc.RefreshTokens(context.Background(), rotated, "203.0.113.7", "test"); err != nil {
+		t.Errorf("rotated refresh token does not work: %v", err)
+	}
+}
+
+func TestIssueTokensRecordsAuditEventsLast(t *testing.T) {
+	svc, repo := newTestAuthService(t)
+	user := repo.store.users[1]
+	user.UserAgent = "new browser"
+
+	if _, _, err := svc.IssueTokens(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.store.emails) != 1 {
+		t.Errorf("queued %d emails, want the new device notice", len(repo.store.emails))
+	}
+	if got := strings.Join(repo.store.audit, ","); got != models.AuditLogin+","+models.AuditNewDevice {
+		t.Errorf("audited %q", got)
+	}
+}
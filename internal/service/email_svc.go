@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+)
+
+const (
+	emailBatchSize   = 20
+	emailSendTimeout = 30 * time.Second
+	// emailLease keeps a claimed email from being sent twice while the
+	// mail server is slow to answer.
+	emailLease        = 2 * time.Minute
+	emailMaxAttempts  = 8
+	emailBaseBackoff  = 30 * time.Second
+	emailMaxBackoff   = time.Hour
+	defaultEmailLimit = 50
+)
+
+type EmailService interface {
+	GetEmails(ctx context.Context, filter models.EmailFilter) ([]*models.Email, error)
+	Retry(ctx context.Context, id int64) error
+	Dispatch(ctx context.Context) (int, error)
+	Preview(name, locale string) (*mailer.Message, error)
+}
+
+type emailService struct {
+	repo      repository.Repository
+	mailer    mailer.Mailer
+	templates *mailer.Templates
+}
+
+func NewEmailService(repo repository.Repository, mail mailer.Mailer, templates *mailer.Templates) EmailService {
+	return &emailService{
+		repo:      repo,
+		mailer:    mail,
+		templates: templates,
+	}
+}
+
+func (s *emailService) GetEmails(ctx context.Context, filter models.EmailFilter) ([]*models.Email, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultEmailLimit
+	}
+
+	emails, err := s.repo.Emails().GetEmails(ctx, filter)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get emails", err)
+	}
+	return emails, nil
+}
+
+// Retry queues a failed or bounced email again.
+func (s *emailService) Retry(ctx context.Context, id int64) error {
+	if err := s.repo.Emails().RequeueEmail(ctx, id); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return errors.NotFound("no failed or bounced email with this id", err)
+		}
+		return errors.InternalServerError("failed to requeue email", err)
+	}
+	return nil
+}
+
+// Preview renders the named template with sample data. Nothing is sent.
+func (s *emailService) Preview(name, locale string) (*mailer.Message, error) {
+	if !slices.Contains(mailer.TemplateNames, name) {
+		return nil, errors.NotFound("email template not found", nil)
+	}
+
+	msg, err := s.templates.Render(name, locale, mailer.PreviewVars(name, s.templates.Brand()))
+	if err != nil {
+		return nil, errors.InternalServerError("failed to render email", err)
+	}
+	return &msg, nil
+}
+
+// Dispatch sends one batch of due emails and returns how many it attempted,
+// so callers can keep going while there is a backlog.
+func (s *emailService) Dispatch(ctx context.Context) (int, error) {
+	emails, err := s.repo.Emails().ClaimDueEmails(ctx, emailBatchSize, emailLease)
+	if err != nil {
+		return 0, errors.InternalServerError("failed to claim emails", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(emails))
+	for i, email := range emails {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.send(ctx, email)
+		}()
+	}
+	wg.Wait()
+
+	if err := goerror.Join(errs...); err != nil {
+		return len(emails), errors.InternalServerError("failed to record email status", err)
+	}
+	return len(emails), nil
+}
+
+// send hands email to the mailer and records the outcome. Temporary failures
+// are retried with exponential backoff until emailMaxAttempts; permanent
+// rejections are not retried.
+func (s *emailService) send(ctx context.Context, email *models.Email) error {
+	sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+	defer cancel()
+
+	now := time.Now()
+	email.Attempts++
+	email.LastAttemptAt = &now
+	email.LastError = ""
+
+	err := s.mailer.Send(sendCtx, mailer.Message{
+		To:      email.To,
+		Subject: email.Subject,
+		HTML:    email.HTMLBody,
+		Text:    email.TextBody,
+	})
+
+	switch {
+	case err == nil:
+		email.Status = models.EmailSent
+		email.SentAt = &now
+	case mailer.IsPermanent(err):
+		email.Status = models.EmailBounced
+		email.LastError = err.Error()
+	case email.Attempts >= emailMaxAttempts:
+		email.Status = models.EmailFailed
+		email.LastError = err.Error()
+	default:
+		email.LastError = err.Error()
+		email.NextAttemptAt = now.Add(retryBackoff(email.Attempts, emailBaseBackoff, emailMaxBackoff))
+	}
+
+	return s.repo.Emails().UpdateEmail(ctx, *email)
+}
+
+// enqueueTemplateEmail renders the named template in the recipient's locale
+// and queues it like enqueueEmail.
+func enqueueTemplateEmail(ctx context.Context, emails repository.EmailRepository, templates *mailer.Templates, name, to, locale string, vars map[string]any) error {
+	msg, err := templates.Render(name, locale, vars)
+	if err != nil {
+		return errors.InternalServerError("failed to render email", err)
+	}
+	msg.To = to
+	return enqueueEmail(ctx, emails, msg)
+}
+
+// emailURL returns the link to path on the frontend the emails point at.
+func emailURL(templates *mailer.Templates, path string) string {
+	return templates.Brand().BaseURL + path
+}
+
+// emailTime formats t for display in emails, which cannot know the
+// recipient's time zone.
+func emailTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// enqueueEmail adds msg to the outgoing queue through emails. Passing the
+// repository of a transaction sends the email only if the transaction
+// commits.
+func enqueueEmail(ctx context.Context, emails repository.EmailRepository, msg mailer.Message) error {
+	if _, err := emails.CreateEmail(ctx, models.Email{
+		To:       msg.To,
+		Subject:  msg.Subject,
+		HTMLBody: msg.HTML,
+		TextBody: msg.Text,
+	}); err != nil {
+		return errors.InternalServerError("failed to queue email", err)
+	}
+	return nil
+}
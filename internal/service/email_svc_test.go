@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+var emailLink = regexp.MustCompile(`https://app\.test/[^\s"<]+`)
+
+// deliver sends the queued emails through a memory transport and returns
+// the link in the last message sent.
+func deliver(t *testing.T, repo *memRepository, templates *mailer.Templates) (mailer.Message, *url.URL) {
+	t.Helper()
+	transport := mailer.NewMemoryMailer()
+	if _, err := NewEmailService(repo, transport, templates).Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := transport.Last()
+	if !ok {
+		t.Fatal("no email was sent")
+	}
+	link, err := url.Parse(emailLink.FindString(msg.Text))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("email has no link with a token: %q", msg.Text)
+	}
+	return msg, link
+}
+
+func TestVerificationEmailLinkVerifiesTheAddress(t *testing.T) {
+	repo, templates := newTestRepository(t)
+	user := repo.store.users[1]
+	user.IsVerified = false
+	repo.store.users[1] = user
+	svc := NewAuthService(repo, templates)
+
+	if err := svc.CreateVerifyEmail(context.Background(), "alice@example.test"); err != nil {
+		t.Fatal(err)
+	}
+	msg, link := deliver(t, repo, templates)
+	if msg.To != "alice@example.test" || link.Path != "/verify-email" {
+		t.Errorf("sent %q to %q, want the verification link for alice", link, msg.To)
+	}
+	if repo.store.emails[0].Status != models.EmailSent {
+		t.Errorf("email status %q, want %q", repo.store.emails[0].Status, models.EmailSent)
+	}
+
+	if err := svc.VerifyEmail(context.Background(), link.Query().Get("token")); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.store.users[1].IsVerified {
+		t.Error("email is not verified")
+	}
+}
+
+func TestResetEmailLinkResetsThePassword(t *testing.T) {
+	repo, templates := newTestRepository(t)
+	svc := NewAuthService(repo, templates)
+
+	if err := svc.ForgotPassword(context.Background(), "alice@example.test"); err != nil {
+		t.Fatal(err)
+	}
+	msg, link := deliver(t, repo, templates)
+	if msg.To != "alice@example.test" || link.Path != "/reset-password" {
+		t.Errorf("sent %q to %q, want the reset link for alice", link, msg.To)
+	}
+
+	if err := svc.ResetPassword(context.Background(), link.Query().Get("token"), "new-password"); err != nil {
+		t.Fatal(err)
+	}
+}
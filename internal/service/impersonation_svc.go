@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/google/uuid"
+)
+
+type ImpersonationService interface {
+	StartImpersonation(ctx context.Context, actor models.User, targetID int, reason, ip, userAgent string) (string, *models.ImpersonationSession, error)
+	StopImpersonation(ctx context.Context, user models.User, jti, ip, userAgent string) error
+	CheckImpersonation(ctx context.Context, jti string) error
+}
+
+type impersonationService struct {
+	repo repository.Repository
+}
+
+func NewImpersonationService(repo repository.Repository) ImpersonationService {
+	return &impersonationService{
+		repo: repo,
+	}
+}
+
+// StartImpersonation issues a short-lived access token for targetID that
+// names actor in its act claim. There is no refresh token, so the session
+// ends when the token expires or is stopped.
+func (s *impersonationService) StartImpersonation(ctx context.Context, actor models.User, targetID int, reason, ip, userAgent string) (string, *models.ImpersonationSession, error) {
+	if actor.Actor != nil {
+		return "", nil, errors.Forbidden("cannot impersonate while impersonating", nil)
+	}
+	if actor.ID == targetID {
+		return "", nil, errors.BadRequest("you cannot impersonate yourself", nil)
+	}
+
+	target, err := s.repo.Users().GetUserByID(ctx, targetID)
+	if err != nil {
+		return "", nil, errors.InternalServerError("failed to get user by id", err)
+	}
+	if target == nil {
+		return "", nil, errors.NotFound("user not found", nil)
+	}
+	if err := ensureUserActive(target); err != nil {
+		return "", nil, errors.BadRequest("cannot impersonate an inactive account", err)
+	}
+
+	roles, err := s.repo.Roles().GetUserRoles(ctx, targetID)
+	if err != nil {
+		return "", nil, errors.InternalServerError("failed to get user roles", err)
+	}
+	if slices.Contains(roles, AdminRole) {
+		return "", nil, errors.Forbidden("admins cannot be impersonated", nil)
+	}
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, targetID)
+	if err != nil {
+		return "", nil, errors.InternalServerError("failed to get user permissions", err)
+	}
+	target.Roles = roles
+	target.Permissions = permissions
+	target.Actor = &models.Actor{ID: actor.ID, Email: actor.Email}
+
+	token, jti, err := utils.GenerateJWT(*target, "impersonation")
+	if err != nil {
+		return "", nil, errors.InternalServerError("failed to generate impersonation token", err)
+	}
+
+	now := time.Now()
+	session := &models.ImpersonationSession{
+		ID:        uuid.New(),
+		ActorID:   &actor.ID,
+		TargetID:  targetID,
+		JTI:       jti,
+		Reason:    reason,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		StartedAt: now,
+		ExpiredAt: now.Add(utils.ImpersonationTTL),
+	}
+
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Auth().CreateImpersonationSession(ctx, *session); err != nil {
+			return errors.InternalServerError("failed to create impersonation session", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &actor.ID,
+			Action:     models.AuditImpersonationStart,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(targetID),
+			IPAddress:  ip,
+			UserAgent:  userAgent,
+		}, map[string]any{
+			"session_id": session.ID,
+			"reason":     reason,
+			"expires_at": session.ExpiredAt,
+		})
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, session, nil
+}
+
+func (s *impersonationService) StopImpersonation(ctx context.Context, user models.User, jti, ip, userAgent string) error {
+	if user.Actor == nil {
+		return errors.BadRequest("not impersonating", nil)
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		session, err := u.Auth().GetImpersonationSessionByJTI(ctx, jti)
+		if err != nil {
+			return errors.InternalServerError("failed to get impersonation session", err)
+		}
+		if session == nil {
+			return errors.NotFound("impersonation session not found", nil)
+		}
+
+		if err := u.Auth().EndImpersonationSession(ctx, jti); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.BadRequest("impersonation already ended", err)
+			}
+			return errors.InternalServerError("failed to end impersonation session", err)
+		}
+
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &user.Actor.ID,
+			Action:     models.AuditImpersonationStop,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(user.ID),
+			IPAddress:  ip,
+			UserAgent:  userAgent,
+		}, map[string]any{
+			"session_id": session.ID,
+		})
+	})
+}
+
+// CheckImpersonation rejects impersonation tokens whose session was stopped.
+func (s *impersonationService) CheckImpersonation(ctx context.Context, jti string) error {
+	session, err := s.repo.Auth().GetImpersonationSessionByJTI(ctx, jti)
+	if err != nil {
+		return errors.InternalServerError("failed to get impersonation session", err)
+	}
+	if session == nil || session.EndedAt != nil {
+		return errors.Unauthorized("impersonation has ended", nil)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"github.com/Jonathan0823/auth-go/internal/mailer"
 	"github.com/Jonathan0823/auth-go/internal/repository"
 )
 
@@ -8,16 +9,66 @@ type Service interface {
 	User() UserService
 	OAuth() OAuthService
 	Auth() AuthService
+	Roles() RoleService
+	Organizations() OrganizationService
+	Admin() AdminService
+	Impersonation() ImpersonationService
+	Tokens() TokenService
+	ServiceAccounts() ServiceAccountService
+	Audit() AuditService
+	Webhooks() WebhookService
+	Emails() EmailService
+	Maintenance() MaintenanceService
 }
 
 type service struct {
-	repo repository.Repository
+	repo         repository.Repository
+	authBackends []AuthBackend
+	mailer       mailer.Mailer
+	templates    *mailer.Templates
 }
 
-func NewService(repo repository.Repository) Service {
-	return &service{
+type Option func(*service)
+
+// WithAuthBackends adds login backends that are tried before the local
+// password backend.
+func WithAuthBackends(backends ...AuthBackend) Option {
+	return func(s *service) {
+		s.authBackends = append(s.authBackends, backends...)
+	}
+}
+
+// WithMailer sets the transport the email queue is sent through. The default
+// prints messages to the log.
+func WithMailer(m mailer.Mailer) Option {
+	return func(s *service) {
+		s.mailer = m
+	}
+}
+
+// WithEmailTemplates sets the templates emails are rendered from. The
+// default is the embedded templates with neutral branding.
+func WithEmailTemplates(templates *mailer.Templates) Option {
+	return func(s *service) {
+		s.templates = templates
+	}
+}
+
+func NewService(repo repository.Repository, opts ...Option) Service {
+	s := &service{
 		repo: repo,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.authBackends = append(s.authBackends, NewLocalBackend(repo))
+	if s.mailer == nil {
+		s.mailer = mailer.NewLogMailer("")
+	}
+	if s.templates == nil {
+		s.templates = mailer.DefaultTemplates()
+	}
+	return s
 }
 
 func (s *service) User() UserService {
@@ -29,5 +80,45 @@ func (s *service) OAuth() OAuthService {
 }
 
 func (s *service) Auth() AuthService {
-	return NewAuthService(s.repo)
+	return NewAuthService(s.repo, s.templates, s.authBackends...)
+}
+
+func (s *service) Roles() RoleService {
+	return NewRoleService(s.repo)
+}
+
+func (s *service) Organizations() OrganizationService {
+	return NewOrganizationService(s.repo, s.templates)
+}
+
+func (s *service) Admin() AdminService {
+	return NewAdminService(s.repo, s.templates)
+}
+
+func (s *service) Impersonation() ImpersonationService {
+	return NewImpersonationService(s.repo)
+}
+
+func (s *service) Tokens() TokenService {
+	return NewTokenService(s.repo)
+}
+
+func (s *service) ServiceAccounts() ServiceAccountService {
+	return NewServiceAccountService(s.repo)
+}
+
+func (s *service) Audit() AuditService {
+	return NewAuditService(s.repo)
+}
+
+func (s *service) Webhooks() WebhookService {
+	return NewWebhookService(s.repo)
+}
+
+func (s *service) Emails() EmailService {
+	return NewEmailService(s.repo, s.mailer, s.templates)
+}
+
+func (s *service) Maintenance() MaintenanceService {
+	return NewMaintenanceService(s.repo)
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+)
+
+// DefaultPurgeRetention is how long expired and revoked records are kept
+// for investigations before they are purged.
+const DefaultPurgeRetention = 30 * 24 * time.Hour
+
+// jobRunLog is how many runs GetJobRuns returns.
+const jobRunLog = 100
+
+type MaintenanceService interface {
+	PurgeExpired(ctx context.Context, retention time.Duration) (*models.PurgeReport, error)
+	RecordJobRun(ctx context.Context, run models.JobRun) error
+	GetJobSummaries(ctx context.Context) ([]*models.JobSummary, error)
+	GetJobRuns(ctx context.Context, job string) ([]*models.JobRun, error)
+}
+
+type maintenanceService struct {
+	repo repository.Repository
+}
+
+func NewMaintenanceService(repo repository.Repository) MaintenanceService {
+	return &maintenanceService{
+		repo: repo,
+	}
+}
+
+// PurgeExpired deletes used link token nonces once their token has expired,
+// expired links from before links were signed, and refresh tokens and
+// unaccepted invitations that expired or were revoked more than retention
+// ago. Job runs, sent, failed or bounced emails, processed webhook events and
+// finished webhook deliveries older than retention go as well.
+func (s *maintenanceService) PurgeExpired(ctx context.Context, retention time.Duration) (*models.PurgeReport, error) {
+	before := time.Now().Add(-retention)
+	report := new(models.PurgeReport)
+
+	var err error
+	if report.LinkNonces, err = s.repo.Auth().DeleteExpiredLinkNonces(ctx); err != nil {
+		return nil, errors.InternalServerError("failed to purge link token nonces", err)
+	}
+	if report.LegacyLinks, err = s.repo.Auth().DeleteExpiredLegacyLinks(ctx); err != nil {
+		return nil, errors.InternalServerError("failed to purge legacy links", err)
+	}
+	if report.TokenLogs, err = s.repo.Auth().DeleteExpiredTokenLogs(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge token logs", err)
+	}
+	if report.Invitations, err = s.repo.Organizations().DeleteExpiredInvitations(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge invitations", err)
+	}
+	if report.JobRuns, err = s.repo.Jobs().DeleteJobRuns(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge job runs", err)
+	}
+	if report.Emails, err = s.repo.Emails().DeleteFinishedEmails(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge emails", err)
+	}
+	if report.WebhookEvents, err = s.repo.Webhooks().DeleteProcessedOutboxEvents(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge webhook events", err)
+	}
+	if report.WebhookDeliveries, err = s.repo.Webhooks().DeleteFinishedDeliveries(ctx, before); err != nil {
+		return nil, errors.InternalServerError("failed to purge webhook deliveries", err)
+	}
+	return report, nil
+}
+
+func (s *maintenanceService) RecordJobRun(ctx context.Context, run models.JobRun) error {
+	if _, err := s.repo.Jobs().CreateJobRun(ctx, run); err != nil {
+		return errors.InternalServerError("failed to record job run", err)
+	}
+	return nil
+}
+
+func (s *maintenanceService) GetJobSummaries(ctx context.Context) ([]*models.JobSummary, error) {
+	summaries, err := s.repo.Jobs().GetJobSummaries(ctx)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get job summaries", err)
+	}
+	return summaries, nil
+}
+
+// GetJobRuns returns the job's 100 most recent runs.
+func (s *maintenanceService) GetJobRuns(ctx context.Context, job string) ([]*models.JobRun, error) {
+	runs, err := s.repo.Jobs().GetJobRuns(ctx, job, jobRunLog)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get job runs", err)
+	}
+	return runs, nil
+}
@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	goerror "errors"
+	"slices"
+	"strconv"
 
 	"github.com/Jonathan0823/auth-go/internal/errors"
 	"github.com/Jonathan0823/auth-go/internal/models"
@@ -10,7 +14,10 @@ import (
 )
 
 type OAuthService interface {
-	OAuthLogin(ctx context.Context, user models.User) (*models.User, error)
+	OAuthLogin(ctx context.Context, user models.User, emailVerified bool) (*models.User, error)
+	LinkIdentity(ctx context.Context, userID int, user models.User) error
+	UnlinkIdentity(ctx context.Context, userID int, provider string) error
+	GetIdentities(ctx context.Context, userID int) ([]*models.UserIdentity, error)
 }
 
 type oAuthService struct {
@@ -23,16 +30,262 @@ func NewOAuthService(repo repository.Repository) OAuthService {
 	}
 }
 
-func (s *oAuthService) OAuthLogin(ctx context.Context, user models.User) (*models.User, error) {
-	if err := s.repo.Users().CreateUser(ctx, user); err != nil {
-		if !utils.IsPGUniqueViolation(err) {
-			return nil, errors.InternalServerError("failed to create user", err)
+// OAuthLogin resolves the account for a provider login. Known identities sign
+// in directly; an existing account with the same email is only linked when
+// the provider reports the email as verified. Linking to an account whose
+// email was never verified clears its password and sessions.
+func (s *oAuthService) OAuthLogin(ctx context.Context, user models.User, emailVerified bool) (*models.User, error) {
+	var resolved *models.User
+	err := s.repo.WithTx(ctx, func(u repository.UOW) error {
+		var (
+			metadata map[string]any
+			err      error
+		)
+		resolved, metadata, err = resolveProviderLogin(ctx, u, user, emailVerified)
+		if err != nil {
+			return err
 		}
+		return recordAuditEvent(ctx, u.Audit(), oAuthLoginEvent(resolved.ID), metadata)
+	})
+	if err != nil {
+		return nil, err
 	}
-	userData, err := s.repo.Users().GetUserByEmail(ctx, user.Email, false)
-	if err != nil || userData == nil {
-		return nil, errors.InternalServerError("failed to retrieve user after creation", err)
+	return resolved, nil
+}
+
+// resolveProviderLogin does the work of OAuthLogin in u, so that backends can
+// make further changes to the account in the same transaction. It returns the
+// metadata of the login's audit event, which the caller records as the last
+// write of the transaction.
+func resolveProviderLogin(ctx context.Context, u repository.UOW, user models.User, emailVerified bool) (*models.User, map[string]any, error) {
+	if user.OAuthID == "" || user.Provider == "" {
+		return nil, nil, errors.BadRequest("provider did not return a user id", nil)
+	}
+
+	identity, err := u.Identities().GetIdentity(ctx, user.Provider, user.OAuthID)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get identity", err)
+	}
+	if identity != nil {
+		existing, err := getUserByID(ctx, u.Users(), identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := ensureUserActive(existing); err != nil {
+			return nil, nil, err
+		}
+		return existing, map[string]any{"provider": user.Provider}, nil
+	}
+
+	if user.Email == "" {
+		return nil, nil, errors.BadRequest("provider did not return an email address", nil)
+	}
+
+	existing, err := u.Users().GetUserByEmail(ctx, user.Email, false)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get user by email", err)
+	}
+
+	if existing != nil {
+		if err := ensureUserActive(existing); err != nil {
+			return nil, nil, err
+		}
+		if !emailVerified {
+			return nil, nil, errors.Conflict("an account with this email already exists, log in and link this provider from your account", nil)
+		}
+		// Nobody proved they own the email of an unverified account, so
+		// whoever registered it could be an attacker waiting for the real
+		// owner. The provider has verified the owner: drop every credential
+		// that may not be theirs before linking.
+		if !existing.IsVerified {
+			if err := claimUnverifiedAccount(ctx, u, existing.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := createIdentity(ctx, u.Identities(), existing.ID, user); err != nil {
+			return nil, nil, err
+		}
+		metadata := map[string]any{
+			"provider": user.Provider,
+			"linked":   true,
+			"claimed":  !existing.IsVerified,
+		}
+		if !existing.IsVerified {
+			if existing, err = getUserByID(ctx, u.Users(), existing.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		return existing, metadata, nil
+	}
+
+	user.IsVerified = emailVerified
+	id, err := u.Users().CreateUser(ctx, user)
+	if err != nil {
+		if utils.IsPGUniqueViolation(err) {
+			return nil, nil, errors.Conflict("email already exists", err)
+		}
+		return nil, nil, errors.InternalServerError("failed to create user", err)
+	}
+	if err := createIdentity(ctx, u.Identities(), id, user); err != nil {
+		return nil, nil, err
+	}
+	if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserRegistered, map[string]any{
+		"id":       id,
+		"email":    user.Email,
+		"username": user.Username,
+		"provider": user.Provider,
+	}); err != nil {
+		return nil, nil, err
+	}
+	created, err := getUserByID(ctx, u.Users(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, map[string]any{"provider": user.Provider, "created": true}, nil
+}
+
+func (s *oAuthService) LinkIdentity(ctx context.Context, userID int, user models.User) error {
+	if user.OAuthID == "" || user.Provider == "" {
+		return errors.BadRequest("provider did not return a user id", nil)
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		identity, err := u.Identities().GetIdentity(ctx, user.Provider, user.OAuthID)
+		if err != nil {
+			return errors.InternalServerError("failed to get identity", err)
+		}
+		if identity != nil {
+			if identity.UserID == userID {
+				return nil
+			}
+			return errors.Conflict("this provider account is already linked to another user", nil)
+		}
+
+		if err := createIdentity(ctx, u.Identities(), userID, user); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), identityEvent(models.AuditIdentityLink, userID), map[string]any{
+			"provider":         user.Provider,
+			"provider_user_id": user.OAuthID,
+			"email":            user.Email,
+		})
+	})
+}
+
+func (s *oAuthService) UnlinkIdentity(ctx context.Context, userID int, provider string) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		identities, err := u.Identities().GetIdentitiesByUserID(ctx, userID)
+		if err != nil {
+			return errors.InternalServerError("failed to get identities", err)
+		}
+		if !slices.ContainsFunc(identities, func(identity *models.UserIdentity) bool {
+			return identity.Provider == provider
+		}) {
+			return errors.NotFound("identity not found", nil)
+		}
+
+		user, err := u.Users().GetUserByID(ctx, userID)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by id", err)
+		}
+		if user == nil {
+			return errors.NotFound("user not found", nil)
+		}
+
+		userWithPassword, err := u.Users().GetUserByEmail(ctx, user.Email, true)
+		if err != nil || userWithPassword == nil {
+			return errors.InternalServerError("failed to get user by email", err)
+		}
+
+		if userWithPassword.Password == "" && len(identities) <= 1 {
+			return errors.BadRequest("cannot unlink the only sign-in method, set a password first", nil)
+		}
+
+		if err := u.Identities().DeleteIdentity(ctx, userID, provider); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("identity not found", err)
+			}
+			return errors.InternalServerError("failed to unlink identity", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), identityEvent(models.AuditIdentityUnlink, userID), map[string]any{"provider": provider})
+	})
+}
+
+func (s *oAuthService) GetIdentities(ctx context.Context, userID int) ([]*models.UserIdentity, error) {
+	identities, err := s.repo.Identities().GetIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get identities", err)
 	}
+	return identities, nil
+}
 
+// claimUnverifiedAccount hands an unverified account to the verified owner of
+// its email: the password set at registration stops working, sessions and
+// personal access tokens are revoked, other linked identities are removed
+// and the email counts as verified.
+func claimUnverifiedAccount(ctx context.Context, u repository.UOW, userID int) error {
+	if err := u.Users().UpdateUserPassword(ctx, userID, ""); err != nil {
+		return errors.InternalServerError("failed to clear password", err)
+	}
+	if _, err := u.Auth().InvalidateUserTokenLogs(ctx, userID); err != nil {
+		return errors.InternalServerError("failed to revoke sessions", err)
+	}
+	if _, err := u.Tokens().RevokeUserTokens(ctx, userID); err != nil {
+		return errors.InternalServerError("failed to revoke personal access tokens", err)
+	}
+	if _, err := u.Identities().DeleteIdentitiesByUserID(ctx, userID); err != nil {
+		return errors.InternalServerError("failed to remove linked identities", err)
+	}
+	if err := u.Users().SetUserVerified(ctx, userID); err != nil {
+		return errors.InternalServerError("failed to verify user", err)
+	}
+	return nil
+}
+
+func createIdentity(ctx context.Context, repo repository.IdentityRepository, userID int, user models.User) error {
+	identity := models.UserIdentity{
+		UserID:         userID,
+		Provider:       user.Provider,
+		ProviderUserID: user.OAuthID,
+		Email:          user.Email,
+	}
+	if err := repo.CreateIdentity(ctx, identity); err != nil {
+		if utils.IsPGUniqueViolation(err) {
+			return errors.Conflict("a "+user.Provider+" account is already linked", err)
+		}
+		return errors.InternalServerError("failed to link identity", err)
+	}
+	return nil
+}
+
+// oAuthLoginEvent records how a provider login was resolved to userID. The
+// session itself is audited when its tokens are issued.
+func oAuthLoginEvent(userID int) models.AuditEvent {
+	return models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditOAuthLogin,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	}
+}
+
+// identityEvent records a user linking or unlinking a sign-in method.
+func identityEvent(action string, userID int) models.AuditEvent {
+	return models.AuditEvent{
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	}
+}
+
+func getUserByID(ctx context.Context, users repository.UserRepository, id int) (*models.User, error) {
+	userData, err := users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to retrieve user", err)
+	}
+	if userData == nil {
+		return nil, errors.NotFound("user not found", nil)
+	}
 	return userData, nil
 }
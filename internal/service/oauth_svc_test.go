@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+func TestLinkIdentityRecordsAnAuditEvent(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	svc := NewOAuthService(repo)
+	ctx := context.Background()
+	github := models.User{Provider: "github", OAuthID: "1001", Email: "alice@example.test"}
+
+	if err := svc.LinkIdentity(ctx, 1, github); err != nil {
+		t.Fatal(err)
+	}
+	if identity, ok := repo.store.identities["github|1001"]; !ok || identity.UserID != 1 {
+		t.Fatalf("identities = %v", repo.store.identities)
+	}
+	if !slices.Equal(repo.store.audit, []string{models.AuditIdentityLink}) {
+		t.Errorf("audit = %v", repo.store.audit)
+	}
+
+	// Linking the same account again changes nothing.
+	if err := svc.LinkIdentity(ctx, 1, github); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.store.audit) != 1 {
+		t.Errorf("audit = %v", repo.store.audit)
+	}
+
+	err := svc.LinkIdentity(ctx, 2, github)
+	wantStatus(t, err, http.StatusConflict)
+}
+
+func TestUnlinkIdentityRecordsAnAuditEvent(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	repo.store.identities["github|1001"] = models.UserIdentity{UserID: 1, Provider: "github", ProviderUserID: "1001"}
+	svc := NewOAuthService(repo)
+
+	if err := svc.UnlinkIdentity(context.Background(), 1, "github"); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.store.identities) != 0 {
+		t.Errorf("identities = %v", repo.store.identities)
+	}
+	if !slices.Equal(repo.store.audit, []string{models.AuditIdentityUnlink}) {
+		t.Errorf("audit = %v", repo.store.audit)
+	}
+}
+
+func TestUnlinkIdentityRejectsUnlinkedProviders(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	user := repo.store.users[1]
+	user.Password = ""
+	repo.store.users[1] = user
+	repo.store.identities["github|1001"] = models.UserIdentity{UserID: 1, Provider: "github", ProviderUserID: "1001"}
+	svc := NewOAuthService(repo)
+	ctx := context.Background()
+
+	err := svc.UnlinkIdentity(ctx, 1, "google")
+	wantStatus(t, err, http.StatusNotFound)
+
+	err = svc.UnlinkIdentity(ctx, 1, "github")
+	wantStatus(t, err, http.StatusBadRequest)
+	if len(repo.store.identities) != 1 || len(repo.store.audit) != 0 {
+		t.Errorf("identities = %v, audit = %v", repo.store.identities, repo.store.audit)
+	}
+}
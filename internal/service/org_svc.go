@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	slugPattern    = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, userID int, req models.CreateOrganizationRequest) (*models.Organization, error)
+	GetUserOrganizations(ctx context.Context, userID int) ([]*models.Organization, error)
+	GetMembers(ctx context.Context, orgID int, principal models.Principal) ([]*models.Membership, error)
+	UpdateMemberRole(ctx context.Context, orgID, actorID, userID int, role string) error
+	RemoveMember(ctx context.Context, orgID, actorID, userID int) error
+	InviteMember(ctx context.Context, orgID, inviterID int, req models.InviteMemberRequest) error
+	AcceptInvitation(ctx context.Context, userID int, id string) (*models.Organization, error)
+}
+
+type organizationService struct {
+	repo      repository.Repository
+	templates *mailer.Templates
+}
+
+func NewOrganizationService(repo repository.Repository, templates *mailer.Templates) OrganizationService {
+	return &organizationService{
+		repo:      repo,
+		templates: templates,
+	}
+}
+
+func (s *organizationService) CreateOrganization(ctx context.Context, userID int, req models.CreateOrganizationRequest) (*models.Organization, error) {
+	slug := req.Slug
+	if slug == "" {
+		slug = strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(req.Name), "-"), "-")
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, errors.BadRequest("slug may only contain lowercase letters, digits and hyphens", nil)
+	}
+
+	org := models.Organization{
+		Name:      req.Name,
+		Slug:      slug,
+		Role:      models.OrgRoleOwner,
+		CreatedBy: &userID,
+	}
+
+	err := s.repo.WithTx(ctx, func(u repository.UOW) error {
+		id, err := u.Organizations().CreateOrganization(ctx, org)
+		if err != nil {
+			if utils.IsPGUniqueViolation(err) {
+				return errors.Conflict("slug already exists", err)
+			}
+			return errors.InternalServerError("failed to create organization", err)
+		}
+		org.ID = id
+
+		if err := u.Organizations().CreateMembership(ctx, id, userID, models.OrgRoleOwner); err != nil {
+			return errors.InternalServerError("failed to create membership", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	org.CreatedAt = time.Now()
+	org.UpdatedAt = org.CreatedAt
+	return &org, nil
+}
+
+func (s *organizationService) GetUserOrganizations(ctx context.Context, userID int) ([]*models.Organization, error) {
+	orgs, err := s.repo.Organizations().GetOrganizationsByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get organizations", err)
+	}
+	return orgs, nil
+}
+
+// GetMembers lists the members of orgID to its members and to its own
+// service accounts.
+func (s *organizationService) GetMembers(ctx context.Context, orgID int, principal models.Principal) ([]*models.Membership, error) {
+	if principal.Type == models.PrincipalServiceAccount {
+		if principal.OrgID != orgID {
+			return nil, errors.NotFound("organization not found", nil)
+		}
+	} else if _, err := requireOrgMembership(ctx, s.repo.Organizations(), orgID, principal.ID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.Organizations().GetMembers(ctx, orgID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get members", err)
+	}
+	return members, nil
+}
+
+func (s *organizationService) UpdateMemberRole(ctx context.Context, orgID, actorID, userID int, role string) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := lockOrganization(ctx, u.Organizations(), orgID); err != nil {
+			return err
+		}
+		actor, err := requireOrgManager(ctx, u.Organizations(), orgID, actorID)
+		if err != nil {
+			return err
+		}
+
+		member, err := getOrgMembership(ctx, u.Organizations(), orgID, userID)
+		if err != nil {
+			return err
+		}
+
+		// Only owners may hand out or take away ownership.
+		if (role == models.OrgRoleOwner || member.Role == models.OrgRoleOwner) && actor.Role != models.OrgRoleOwner {
+			return errors.Forbidden("only owners can change ownership", nil)
+		}
+		if member.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			if err := ensureAnotherOwner(ctx, u.Organizations(), orgID); err != nil {
+				return err
+			}
+		}
+
+		if err := u.Organizations().UpdateMembershipRole(ctx, orgID, userID, role); err != nil {
+			return errors.InternalServerError("failed to update member role", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), orgMemberEvent(models.AuditOrgMemberRoleChange, userID), map[string]any{
+			"organization_id": orgID,
+			"role":            role,
+			"previous_role":   member.Role,
+		})
+	})
+}
+
+// RemoveMember removes userID from the organization. Owners and admins can
+// remove other members and every member can remove themselves.
+func (s *organizationService) RemoveMember(ctx context.Context, orgID, actorID, userID int) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := lockOrganization(ctx, u.Organizations(), orgID); err != nil {
+			return err
+		}
+		member, err := getOrgMembership(ctx, u.Organizations(), orgID, userID)
+		if err != nil {
+			return err
+		}
+
+		if actorID != userID {
+			actor, err := requireOrgManager(ctx, u.Organizations(), orgID, actorID)
+			if err != nil {
+				return err
+			}
+			if member.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+				return errors.Forbidden("only owners can remove owners", nil)
+			}
+		}
+
+		if member.Role == models.OrgRoleOwner {
+			if err := ensureAnotherOwner(ctx, u.Organizations(), orgID); err != nil {
+				return err
+			}
+		}
+
+		if err := u.Organizations().DeleteMembership(ctx, orgID, userID); err != nil {
+			return errors.InternalServerError("failed to remove member", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), orgMemberEvent(models.AuditOrgMemberRemove, userID), map[string]any{
+			"organization_id": orgID,
+			"role":            member.Role,
+		})
+	})
+}
+
+func (s *organizationService) InviteMember(ctx context.Context, orgID, inviterID int, req models.InviteMemberRequest) error {
+	inviter, err := requireOrgManager(ctx, s.repo.Organizations(), orgID, inviterID)
+	if err != nil {
+		return err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if role == models.OrgRoleOwner && inviter.Role != models.OrgRoleOwner {
+		return errors.Forbidden("only owners can invite owners", nil)
+	}
+
+	org, err := s.repo.Organizations().GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return errors.InternalServerError("failed to get organization", err)
+	}
+	if org == nil {
+		return errors.NotFound("organization not found", nil)
+	}
+
+	invitation := models.OrganizationInvitation{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Email:          strings.ToLower(req.Email),
+		Role:           role,
+		InvitedBy:      &inviterID,
+		ExpiredAt:      time.Now().Add(7 * 24 * time.Hour),
+	}
+
+	// Invitees who already have an account get the email in their locale.
+	locale := ""
+	invitee, err := s.repo.Users().GetUserByEmail(ctx, invitation.Email, false)
+	if err != nil {
+		return errors.InternalServerError("failed to get user by email", err)
+	}
+	if invitee != nil {
+		locale = invitee.Locale
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Organizations().CreateInvitation(ctx, invitation); err != nil {
+			return errors.InternalServerError("failed to create invitation", err)
+		}
+		return enqueueTemplateEmail(ctx, u.Emails(), s.templates, mailer.TemplateInvitation, invitation.Email, locale, map[string]any{
+			"InviterName":      inviter.Username,
+			"OrganizationName": org.Name,
+			"URL":              emailURL(s.templates, "/accept-invitation?id="+invitation.ID.String()),
+		})
+	})
+}
+
+// AcceptInvitation adds userID to the invitation's organization. The
+// invitation must be addressed to the user's verified email address.
+func (s *organizationService) AcceptInvitation(ctx context.Context, userID int, id string) (*models.Organization, error) {
+	var org *models.Organization
+	err := s.repo.WithTx(ctx, func(u repository.UOW) error {
+		invitation, err := u.Organizations().GetInvitationByID(ctx, id)
+		if err != nil {
+			return errors.InternalServerError("failed to get invitation", err)
+		}
+		if invitation == nil {
+			return errors.NotFound("invitation not found", nil)
+		}
+		if invitation.AcceptedAt != nil {
+			return errors.Conflict("invitation already accepted", nil)
+		}
+		if time.Now().After(invitation.ExpiredAt) {
+			return errors.BadRequest("invitation expired", nil)
+		}
+
+		user, err := u.Users().GetUserByID(ctx, userID)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by id", err)
+		}
+		if user == nil {
+			return errors.NotFound("user not found", nil)
+		}
+		if !user.IsVerified || !strings.EqualFold(user.Email, invitation.Email) {
+			return errors.Forbidden("invitation was sent to a different email address", nil)
+		}
+
+		member, err := u.Organizations().GetMembership(ctx, invitation.OrganizationID, userID)
+		if err != nil {
+			return errors.InternalServerError("failed to get membership", err)
+		}
+		if member != nil {
+			return errors.Conflict("already a member of this organization", nil)
+		}
+
+		if err := u.Organizations().AcceptInvitation(ctx, id); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.Conflict("invitation already accepted", err)
+			}
+			return errors.InternalServerError("failed to accept invitation", err)
+		}
+		if err := u.Organizations().CreateMembership(ctx, invitation.OrganizationID, userID, invitation.Role); err != nil {
+			return errors.InternalServerError("failed to create membership", err)
+		}
+
+		org, err = u.Organizations().GetOrganizationByID(ctx, invitation.OrganizationID)
+		if err != nil {
+			return errors.InternalServerError("failed to get organization", err)
+		}
+		org.Role = invitation.Role
+		return recordAuditEvent(ctx, u.Audit(), orgMemberEvent(models.AuditOrgMemberJoin, userID), map[string]any{
+			"organization_id": invitation.OrganizationID,
+			"role":            invitation.Role,
+			"invited_by":      invitation.InvitedBy,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func getOrgMembership(ctx context.Context, orgs repository.OrganizationRepository, orgID, userID int) (*models.Membership, error) {
+	member, err := orgs.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get membership", err)
+	}
+	if member == nil {
+		return nil, errors.NotFound("member not found", nil)
+	}
+	return member, nil
+}
+
+// requireOrgMembership hides organizations the user does not belong to
+// behind a not found error.
+func requireOrgMembership(ctx context.Context, orgs repository.OrganizationRepository, orgID, userID int) (*models.Membership, error) {
+	member, err := orgs.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get membership", err)
+	}
+	if member == nil {
+		return nil, errors.NotFound("organization not found", nil)
+	}
+	return member, nil
+}
+
+func requireOrgManager(ctx context.Context, orgs repository.OrganizationRepository, orgID, userID int) (*models.Membership, error) {
+	member, err := requireOrgMembership(ctx, orgs, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin {
+		return nil, errors.Forbidden("organization owner or admin role required", nil)
+	}
+	return member, nil
+}
+
+// lockOrganization serializes membership changes of orgID, so that checks
+// such as ensureAnotherOwner see the changes of concurrent transactions.
+func lockOrganization(ctx context.Context, orgs repository.OrganizationRepository, orgID int) error {
+	if err := orgs.LockOrganization(ctx, orgID); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return errors.NotFound("organization not found", nil)
+		}
+		return errors.InternalServerError("failed to lock organization", err)
+	}
+	return nil
+}
+
+// ensureAnotherOwner must run after lockOrganization.
+func ensureAnotherOwner(ctx context.Context, orgs repository.OrganizationRepository, orgID int) error {
+	owners, err := orgs.CountMembersWithRole(ctx, orgID, models.OrgRoleOwner)
+	if err != nil {
+		return errors.InternalServerError("failed to count owners", err)
+	}
+	if owners <= 1 {
+		return errors.BadRequest("organization must keep at least one owner", nil)
+	}
+	return nil
+}
+
+func orgMemberEvent(action string, userID int) models.AuditEvent {
+	return models.AuditEvent{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+// newTestOrganizationService returns a service for organization 1, owned
+// by users 1 and 2 with user 3 as a member.
+func newTestOrganizationService() (OrganizationService, *memRepository) {
+	repo := newMemRepository()
+	repo.store.memberships[[2]int{1, 1}] = models.OrgRoleOwner
+	repo.store.memberships[[2]int{1, 2}] = models.OrgRoleOwner
+	repo.store.memberships[[2]int{1, 3}] = models.OrgRoleMember
+	return NewOrganizationService(repo, nil), repo
+}
+
+func TestUpdateMemberRoleKeepsAnOwnerAndRecordsTheChange(t *testing.T) {
+	svc, repo := newTestOrganizationService()
+	ctx := context.Background()
+
+	if err := svc.UpdateMemberRole(ctx, 1, 1, 2, models.OrgRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if role := repo.store.memberships[[2]int{1, 2}]; role != models.OrgRoleAdmin {
+		t.Errorf("role = %q, want %q", role, models.OrgRoleAdmin)
+	}
+	if want := []string{models.AuditOrgMemberRoleChange}; !slices.Equal(repo.store.audit, want) {
+		t.Errorf("audit = %v, want %v", repo.store.audit, want)
+	}
+
+	err := svc.UpdateMemberRole(ctx, 1, 1, 1, models.OrgRoleMember)
+	wantStatus(t, err, http.StatusBadRequest)
+	if role := repo.store.memberships[[2]int{1, 1}]; role != models.OrgRoleOwner {
+		t.Errorf("last owner was demoted to %q", role)
+	}
+}
+
+func TestRemoveMemberKeepsAnOwnerAndRecordsTheRemoval(t *testing.T) {
+	svc, repo := newTestOrganizationService()
+	ctx := context.Background()
+
+	if err := svc.RemoveMember(ctx, 1, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveMember(ctx, 1, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{models.AuditOrgMemberRemove, models.AuditOrgMemberRemove}; !slices.Equal(repo.store.audit, want) {
+		t.Errorf("audit = %v, want %v", repo.store.audit, want)
+	}
+
+	err := svc.RemoveMember(ctx, 1, 1, 1)
+	wantStatus(t, err, http.StatusBadRequest)
+	if _, ok := repo.store.memberships[[2]int{1, 1}]; !ok {
+		t.Error("last owner was removed")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const AdminRole = "admin"
+
+type RoleService interface {
+	GetAllRoles(ctx context.Context) ([]*models.Role, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+	BootstrapAdmin(ctx context.Context, email, password string) error
+}
+
+type roleService struct {
+	repo repository.Repository
+}
+
+func NewRoleService(repo repository.Repository) RoleService {
+	return &roleService{
+		repo: repo,
+	}
+}
+
+func (s *roleService) GetAllRoles(ctx context.Context) ([]*models.Role, error) {
+	roles, err := s.repo.Roles().GetAllRoles(ctx)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get roles", err)
+	}
+	return roles, nil
+}
+
+func (s *roleService) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.repo.Roles().GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user roles", err)
+	}
+	return roles, nil
+}
+
+func (s *roleService) AssignRole(ctx context.Context, userID int, role string) error {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Roles().AssignRole(ctx, userID, role, "manual"); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("role not found", err)
+			}
+			return errors.InternalServerError("failed to assign role", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminRoleAssign, userID), map[string]any{"role": role})
+	})
+}
+
+func (s *roleService) RemoveRole(ctx context.Context, userID int, role string) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if role == AdminRole {
+			admins, err := u.Roles().CountUsersWithRole(ctx, AdminRole)
+			if err != nil {
+				return errors.InternalServerError("failed to count admins", err)
+			}
+			if admins <= 1 {
+				return errors.BadRequest("cannot remove the last admin", nil)
+			}
+		}
+
+		if err := u.Roles().RemoveRole(ctx, userID, role); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("user does not have this role", err)
+			}
+			return errors.InternalServerError("failed to remove role", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminRoleRemove, userID), map[string]any{"role": role})
+	})
+}
+
+// BootstrapAdmin makes email the first admin, creating a verified account
+// with password when it does not exist yet. It refuses to run once any admin
+// exists.
+func (s *roleService) BootstrapAdmin(ctx context.Context, email, password string) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		admins, err := u.Roles().CountUsersWithRole(ctx, AdminRole)
+		if err != nil {
+			return errors.InternalServerError("failed to count admins", err)
+		}
+		if admins > 0 {
+			return errors.Conflict("an admin already exists", nil)
+		}
+
+		user, err := u.Users().GetUserByEmail(ctx, email, false)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by email", err)
+		}
+
+		var userID int
+		if user != nil {
+			userID = user.ID
+		} else {
+			if len(password) < 8 {
+				return errors.BadRequest("password must be at least 8 characters", nil)
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return errors.InternalServerError("failed to hash password", err)
+			}
+
+			userID, err = u.Users().CreateUser(ctx, models.User{
+				Email:      email,
+				Password:   string(hashedPassword),
+				IsVerified: true,
+			})
+			if err != nil {
+				return errors.InternalServerError("failed to create user", err)
+			}
+		}
+
+		if err := u.Roles().AssignRole(ctx, userID, AdminRole, "manual"); err != nil {
+			return errors.InternalServerError("failed to assign admin role", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), adminEvent(models.AuditAdminBootstrap, userID), map[string]any{"email": email})
+	})
+}
+
+func (s *roleService) ensureUserExists(ctx context.Context, userID int) error {
+	user, err := s.repo.Users().GetUserByID(ctx, userID)
+	if err != nil {
+		return errors.InternalServerError("failed to get user by id", err)
+	}
+	if user == nil {
+		return errors.NotFound("user not found", nil)
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+// APIKeyPrefix starts every service account API key so AuthMiddleware can
+// tell them apart from JWTs and personal access tokens.
+const APIKeyPrefix = "ag_live_"
+
+// DefaultKeyRotationOverlap is how long a rotated key keeps working when the
+// rotation request does not say.
+const DefaultKeyRotationOverlap = 24 * time.Hour
+
+// apiKeyUsageDays is how much daily usage GetKeyUsage returns.
+const apiKeyUsageDays = 30
+
+type ServiceAccountService interface {
+	CreateServiceAccount(ctx context.Context, orgID, actorID int, req models.CreateServiceAccountRequest) (*models.ServiceAccount, error)
+	GetServiceAccounts(ctx context.Context, orgID, actorID int) ([]*models.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, orgID, actorID, id int) error
+	CreateKey(ctx context.Context, orgID, actorID, accountID int, req models.CreateAPIKeyRequest) (*models.APIKey, string, error)
+	CreateKeyForAccount(ctx context.Context, accountID int, req models.CreateAPIKeyRequest) (*models.APIKey, string, error)
+	GetKeys(ctx context.Context, orgID, actorID, accountID int) ([]*models.APIKey, error)
+	RotateKey(ctx context.Context, orgID, actorID, accountID, keyID int, req models.RotateAPIKeyRequest) (*models.APIKey, string, error)
+	RotateKeyForAccount(ctx context.Context, accountID, keyID int, req models.RotateAPIKeyRequest) (*models.APIKey, string, error)
+	RevokeKey(ctx context.Context, orgID, actorID, accountID, keyID int) error
+	GetKeyUsage(ctx context.Context, orgID, actorID, accountID, keyID int) ([]*models.APIKeyUsage, error)
+	AuthenticateKey(ctx context.Context, key, ip string) (*models.Principal, error)
+}
+
+type serviceAccountService struct {
+	repo repository.Repository
+}
+
+func NewServiceAccountService(repo repository.Repository) ServiceAccountService {
+	return &serviceAccountService{
+		repo: repo,
+	}
+}
+
+func (s *serviceAccountService) CreateServiceAccount(ctx context.Context, orgID, actorID int, req models.CreateServiceAccountRequest) (*models.ServiceAccount, error) {
+	if _, err := requireOrgManager(ctx, s.repo.Organizations(), orgID, actorID); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(req.Scopes, models.ScopeRead) && !slices.Contains(req.Scopes, models.ScopeWrite) {
+		return nil, errors.BadRequest("scopes must include read or write", nil)
+	}
+
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, actorID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user permissions", err)
+	}
+	for _, scope := range req.Scopes {
+		if scope != models.ScopeRead && scope != models.ScopeWrite && !slices.Contains(permissions, scope) {
+			return nil, errors.BadRequest("unknown or unavailable scope: "+scope, nil)
+		}
+	}
+
+	slices.Sort(req.Scopes)
+	account := &models.ServiceAccount{
+		OrganizationID: orgID,
+		Name:           strings.TrimSpace(req.Name),
+		Description:    req.Description,
+		Scopes:         slices.Compact(req.Scopes),
+		CreatedBy:      &actorID,
+		CreatedAt:      time.Now(),
+	}
+
+	id, err := s.repo.ServiceAccounts().CreateServiceAccount(ctx, *account)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to create service account", err)
+	}
+	account.ID = id
+
+	return account, nil
+}
+
+func (s *serviceAccountService) GetServiceAccounts(ctx context.Context, orgID, actorID int) ([]*models.ServiceAccount, error) {
+	if _, err := requireOrgManager(ctx, s.repo.Organizations(), orgID, actorID); err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.repo.ServiceAccounts().GetServiceAccountsByOrgID(ctx, orgID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get service accounts", err)
+	}
+	return accounts, nil
+}
+
+func (s *serviceAccountService) DeleteServiceAccount(ctx context.Context, orgID, actorID, id int) error {
+	if _, err := requireOrgManager(ctx, s.repo.Organizations(), orgID, actorID); err != nil {
+		return err
+	}
+
+	if err := s.repo.ServiceAccounts().DeleteServiceAccount(ctx, orgID, id); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return errors.NotFound("service account not found", err)
+		}
+		return errors.InternalServerError("failed to delete service account", err)
+	}
+	return nil
+}
+
+// CreateKey returns the stored key and its plaintext, which is not kept and
+// cannot be shown again.
+func (s *serviceAccountService) CreateKey(ctx context.Context, orgID, actorID, accountID int, req models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if _, err := s.requireServiceAccount(ctx, orgID, actorID, accountID); err != nil {
+		return nil, "", err
+	}
+	return s.CreateKeyForAccount(ctx, accountID, req)
+}
+
+// CreateKeyForAccount is CreateKey without the organization check, for
+// operators on the command line.
+func (s *serviceAccountService) CreateKeyForAccount(ctx context.Context, accountID int, req models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", errors.BadRequest("expires_at must be in the future", nil)
+	}
+	if err := s.ensureServiceAccountExists(ctx, accountID); err != nil {
+		return nil, "", err
+	}
+
+	return s.createKey(ctx, s.repo.ServiceAccounts(), models.APIKey{
+		ServiceAccountID: accountID,
+		AllowedIPs:       req.AllowedIPs,
+		ExpiresAt:        req.ExpiresAt,
+	})
+}
+
+func (s *serviceAccountService) GetKeys(ctx context.Context, orgID, actorID, accountID int) ([]*models.APIKey, error) {
+	if _, err := s.requireServiceAccount(ctx, orgID, actorID, accountID); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.repo.ServiceAccounts().GetAPIKeys(ctx, accountID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get api keys", err)
+	}
+	return keys, nil
+}
+
+// RotateKey issues a replacement for keyID with the same IP allowlist and
+// expiry. The old key keeps working for the overlap so callers can switch
+// over without downtime.
+func (s *serviceAccountService) RotateKey(ctx context.Context, orgID, actorID, accountID, keyID int, req models.RotateAPIKeyRequest) (*models.APIKey, string, error) {
+	if _, err := s.requireServiceAccount(ctx, orgID, actorID, accountID); err != nil {
+		return nil, "", err
+	}
+	return s.RotateKeyForAccount(ctx, accountID, keyID, req)
+}
+
+// RotateKeyForAccount is RotateKey without the organization check, for
+// operators on the command line.
+func (s *serviceAccountService) RotateKeyForAccount(ctx context.Context, accountID, keyID int, req models.RotateAPIKeyRequest) (*models.APIKey, string, error) {
+	overlap := DefaultKeyRotationOverlap
+	if req.OverlapHours != nil {
+		overlap = time.Duration(*req.OverlapHours) * time.Hour
+	}
+
+	var (
+		key       *models.APIKey
+		plaintext string
+	)
+	err := s.repo.WithTx(ctx, func(u repository.UOW) error {
+		old, err := u.ServiceAccounts().GetAPIKey(ctx, accountID, keyID)
+		if err != nil {
+			return errors.InternalServerError("failed to get api key", err)
+		}
+		if old == nil || old.RevokedAt != nil || (old.ExpiresAt != nil && !old.ExpiresAt.After(time.Now())) {
+			return errors.NotFound("api key not found", nil)
+		}
+
+		key, plaintext, err = s.createKey(ctx, u.ServiceAccounts(), models.APIKey{
+			ServiceAccountID: accountID,
+			AllowedIPs:       old.AllowedIPs,
+			RotatedFromID:    &old.ID,
+			ExpiresAt:        old.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := u.ServiceAccounts().ExpireAPIKey(ctx, old.ID, time.Now().Add(overlap)); err != nil {
+			return errors.InternalServerError("failed to expire old api key", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *serviceAccountService) RevokeKey(ctx context.Context, orgID, actorID, accountID, keyID int) error {
+	if _, err := s.requireServiceAccount(ctx, orgID, actorID, accountID); err != nil {
+		return err
+	}
+
+	if err := s.repo.ServiceAccounts().RevokeAPIKey(ctx, accountID, keyID); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return errors.NotFound("api key not found", err)
+		}
+		return errors.InternalServerError("failed to revoke api key", err)
+	}
+	return nil
+}
+
+// GetKeyUsage returns the daily request counts of the last 30 days.
+func (s *serviceAccountService) GetKeyUsage(ctx context.Context, orgID, actorID, accountID, keyID int) ([]*models.APIKeyUsage, error) {
+	if _, err := s.requireServiceAccount(ctx, orgID, actorID, accountID); err != nil {
+		return nil, err
+	}
+
+	key, err := s.repo.ServiceAccounts().GetAPIKey(ctx, accountID, keyID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get api key", err)
+	}
+	if key == nil {
+		return nil, errors.NotFound("api key not found", nil)
+	}
+
+	since := time.Now().AddDate(0, 0, -apiKeyUsageDays)
+	usage, err := s.repo.ServiceAccounts().GetAPIKeyUsage(ctx, key.ID, since)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get api key usage", err)
+	}
+	return usage, nil
+}
+
+// AuthenticateKey resolves an API key to its service account and records the
+// use. Keys with an IP allowlist are rejected from any other address. The
+// principal carries the permissions among the account's scopes that its
+// creator still holds, and none once the creator has left the organization.
+func (s *serviceAccountService) AuthenticateKey(ctx context.Context, key, ip string) (*models.Principal, error) {
+	apiKey, err := s.repo.ServiceAccounts().GetActiveAPIKeyByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get api key", err)
+	}
+	if apiKey == nil {
+		return nil, errors.Unauthorized("Unauthorized: invalid api key", nil)
+	}
+	if !ipAllowed(apiKey.AllowedIPs, ip) {
+		return nil, errors.Forbidden("Forbidden: api key is not allowed from this address", nil)
+	}
+
+	account, err := s.repo.ServiceAccounts().GetServiceAccountByID(ctx, apiKey.ServiceAccountID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get service account", err)
+	}
+	if account == nil {
+		return nil, errors.Unauthorized("Unauthorized: invalid api key", nil)
+	}
+
+	permissions, err := s.creatorPermissions(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ServiceAccounts().RecordAPIKeyUsage(ctx, apiKey.ID, ip); err != nil {
+		return nil, errors.InternalServerError("failed to record api key use", err)
+	}
+
+	return &models.Principal{
+		Type:        models.PrincipalServiceAccount,
+		ID:          account.ID,
+		Name:        account.Name,
+		OrgID:       account.OrganizationID,
+		Permissions: permissions,
+		Scopes:      account.Scopes,
+	}, nil
+}
+
+// creatorPermissions returns the permissions among the account's scopes that
+// its creator holds while still a member of the account's organization.
+func (s *serviceAccountService) creatorPermissions(ctx context.Context, account *models.ServiceAccount) ([]string, error) {
+	if account.CreatedBy == nil {
+		return nil, nil
+	}
+
+	membership, err := s.repo.Organizations().GetMembership(ctx, account.OrganizationID, *account.CreatedBy)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get membership", err)
+	}
+	if membership == nil {
+		return nil, nil
+	}
+
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, *account.CreatedBy)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get user permissions", err)
+	}
+	return slices.DeleteFunc(permissions, func(permission string) bool {
+		return !slices.Contains(account.Scopes, permission)
+	}), nil
+}
+
+func (s *serviceAccountService) createKey(ctx context.Context, accounts repository.ServiceAccountRepository, key models.APIKey) (*models.APIKey, string, error) {
+	plaintext, err := utils.GenerateOpaqueToken(APIKeyPrefix)
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to generate api key", err)
+	}
+
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+	key.Prefix = plaintext[:len(APIKeyPrefix)+6]
+	key.CreatedAt = time.Now()
+
+	id, err := accounts.CreateAPIKey(ctx, key, utils.HashToken(plaintext))
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to create api key", err)
+	}
+	key.ID = id
+
+	return &key, plaintext, nil
+}
+
+// requireServiceAccount checks that actorID manages the organization that
+// owns accountID.
+func (s *serviceAccountService) requireServiceAccount(ctx context.Context, orgID, actorID, accountID int) (*models.ServiceAccount, error) {
+	if _, err := requireOrgManager(ctx, s.repo.Organizations(), orgID, actorID); err != nil {
+		return nil, err
+	}
+
+	account, err := s.repo.ServiceAccounts().GetServiceAccount(ctx, orgID, accountID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get service account", err)
+	}
+	if account == nil {
+		return nil, errors.NotFound("service account not found", nil)
+	}
+	return account, nil
+}
+
+func (s *serviceAccountService) ensureServiceAccountExists(ctx context.Context, accountID int) error {
+	account, err := s.repo.ServiceAccounts().GetServiceAccountByID(ctx, accountID)
+	if err != nil {
+		return errors.InternalServerError("failed to get service account", err)
+	}
+	if account == nil {
+		return errors.NotFound("service account not found", nil)
+	}
+	return nil
+}
+
+// ipAllowed reports whether ip matches one of allowed, which holds addresses
+// or CIDR ranges. An empty allowlist allows every address.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+// memServiceAccountRepository adds service accounts to memRepository.
+type memServiceAccountRepository struct {
+	*memRepository
+	accounts *memServiceAccounts
+}
+
+func (r *memServiceAccountRepository) ServiceAccounts() repository.ServiceAccountRepository {
+	return r.accounts
+}
+
+type memServiceAccounts struct {
+	repository.ServiceAccountRepository
+	accounts map[int]models.ServiceAccount
+	keys     map[string]models.APIKey
+}
+
+func (r *memServiceAccounts) CreateServiceAccount(ctx context.Context, account models.ServiceAccount) (int, error) {
+	account.ID = len(r.accounts) + 1
+	r.accounts[account.ID] = account
+	return account.ID, nil
+}
+
+func (r *memServiceAccounts) GetServiceAccountByID(ctx context.Context, id int) (*models.ServiceAccount, error) {
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, nil
+	}
+	return &account, nil
+}
+
+func (r *memServiceAccounts) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	key, ok := r.keys[keyHash]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (r *memServiceAccounts) RecordAPIKeyUsage(ctx context.Context, id int, ip string) error {
+	return nil
+}
+
+func newTestServiceAccountService() (ServiceAccountService, *memServiceAccountRepository) {
+	repo := &memServiceAccountRepository{
+		memRepository: newMemRepository(),
+		accounts:      &memServiceAccounts{accounts: map[int]models.ServiceAccount{}, keys: map[string]models.APIKey{}},
+	}
+	repo.store.permissions[1] = []string{"users:read", "users:write"}
+	repo.store.memberships[[2]int{1, 1}] = models.OrgRoleOwner
+	return NewServiceAccountService(repo), repo
+}
+
+func TestCreateServiceAccountLimitsScopesToTheCreatorsPermissions(t *testing.T) {
+	svc, _ := newTestServiceAccountService()
+	ctx := context.Background()
+
+	account, err := svc.CreateServiceAccount(ctx, 1, 1, models.CreateServiceAccountRequest{Name: "ci", Scopes: []string{"users:read", "read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"read", "users:read"}; !slices.Equal(account.Scopes, want) {
+		t.Errorf("scopes = %v, want %v", account.Scopes, want)
+	}
+
+	_, err = svc.CreateServiceAccount(ctx, 1, 1, models.CreateServiceAccountRequest{Name: "ci", Scopes: []string{"read", "users:delete"}})
+	wantStatus(t, err, http.StatusBadRequest)
+
+	_, err = svc.CreateServiceAccount(ctx, 1, 1, models.CreateServiceAccountRequest{Name: "ci", Scopes: []string{"users:read"}})
+	wantStatus(t, err, http.StatusBadRequest)
+}
+
+func TestAuthenticateKeyGrantsScopedPermissionsTheCreatorStillHolds(t *testing.T) {
+	svc, repo := newTestServiceAccountService()
+	ctx := context.Background()
+	creator := 1
+	repo.accounts.accounts[1] = models.ServiceAccount{
+		ID:             1,
+		OrganizationID: 1,
+		Name:           "ci",
+		Scopes:         []string{"read", "users:read", "users:write"},
+		CreatedBy:      &creator,
+		CreatedAt:      time.Now(),
+	}
+	repo.accounts.keys[utils.HashToken("ag_live_key")] = models.APIKey{ID: 1, ServiceAccountID: 1}
+	repo.store.permissions[1] = []string{"users:read", "users:delete"}
+
+	principal, err := svc.AuthenticateKey(ctx, "ag_live_key", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Type != models.PrincipalServiceAccount || principal.OrgID != 1 {
+		t.Errorf("principal = %+v, want service account of organization 1", principal)
+	}
+	if want := []string{"users:read"}; !slices.Equal(principal.Permissions, want) {
+		t.Errorf("permissions = %v, want %v", principal.Permissions, want)
+	}
+
+	delete(repo.store.memberships, [2]int{1, 1})
+	principal, err = svc.AuthenticateKey(ctx, "ag_live_key", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(principal.Permissions) != 0 {
+		t.Errorf("permissions = %v, want none after the creator left the organization", principal.Permissions)
+	}
+
+	repo.accounts.accounts[1] = models.ServiceAccount{ID: 1, OrganizationID: 1, Scopes: []string{"read", "users:read"}}
+	principal, err = svc.AuthenticateKey(ctx, "ag_live_key", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(principal.Permissions) != 0 {
+		t.Errorf("permissions = %v, want none without a creator", principal.Permissions)
+	}
+}
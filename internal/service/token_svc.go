@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	goerror "errors"
+	"slices"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+)
+
+// PATPrefix starts every personal access token so AuthMiddleware can tell
+// them apart from JWTs.
+const PATPrefix = "ag_pat_"
+
+type TokenService interface {
+	CreateToken(ctx context.Context, userID int, req models.CreateTokenRequest) (*models.PersonalAccessToken, string, error)
+	GetTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, userID, id int) error
+	AuthenticateToken(ctx context.Context, token, ip string) (*models.User, *models.PersonalAccessToken, error)
+}
+
+type tokenService struct {
+	repo repository.Repository
+}
+
+func NewTokenService(repo repository.Repository) TokenService {
+	return &tokenService{
+		repo: repo,
+	}
+}
+
+// CreateToken returns the stored token and its plaintext, which is not kept
+// and cannot be shown again.
+func (s *tokenService) CreateToken(ctx context.Context, userID int, req models.CreateTokenRequest) (*models.PersonalAccessToken, string, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", errors.BadRequest("expires_at must be in the future", nil)
+	}
+	if !slices.Contains(req.Scopes, models.ScopeRead) && !slices.Contains(req.Scopes, models.ScopeWrite) {
+		return nil, "", errors.BadRequest("scopes must include read or write", nil)
+	}
+
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to get user permissions", err)
+	}
+	for _, scope := range req.Scopes {
+		if scope != models.ScopeRead && scope != models.ScopeWrite && !slices.Contains(permissions, scope) {
+			return nil, "", errors.BadRequest("unknown or unavailable scope: "+scope, nil)
+		}
+	}
+
+	plaintext, err := utils.GenerateOpaqueToken(PATPrefix)
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to generate token", err)
+	}
+
+	slices.Sort(req.Scopes)
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    plaintext[:len(PATPrefix)+6],
+		Scopes:    slices.Compact(req.Scopes),
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	id, err := s.repo.Tokens().CreateToken(ctx, *token, utils.HashToken(plaintext))
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to create token", err)
+	}
+	token.ID = id
+
+	return token, plaintext, nil
+}
+
+func (s *tokenService) GetTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	tokens, err := s.repo.Tokens().GetTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get tokens", err)
+	}
+	return tokens, nil
+}
+
+func (s *tokenService) RevokeToken(ctx context.Context, userID, id int) error {
+	if err := s.repo.Tokens().RevokeToken(ctx, userID, id); err != nil {
+		if goerror.Is(err, sql.ErrNoRows) {
+			return errors.NotFound("token not found", err)
+		}
+		return errors.InternalServerError("failed to revoke token", err)
+	}
+	return nil
+}
+
+// AuthenticateToken resolves a personal access token to its owner. The
+// returned user only carries the permissions that are also token scopes.
+func (s *tokenService) AuthenticateToken(ctx context.Context, token, ip string) (*models.User, *models.PersonalAccessToken, error) {
+	pat, err := s.repo.Tokens().GetActiveTokenByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get token", err)
+	}
+	if pat == nil {
+		return nil, nil, errors.Unauthorized("Unauthorized: invalid token", nil)
+	}
+
+	user, err := s.repo.Users().GetUserByID(ctx, pat.UserID)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get user by id", err)
+	}
+	if user == nil {
+		return nil, nil, errors.Unauthorized("Unauthorized: invalid token", nil)
+	}
+	if err := ensureUserActive(user); err != nil {
+		return nil, nil, err
+	}
+
+	roles, err := s.repo.Roles().GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get user roles", err)
+	}
+	permissions, err := s.repo.Roles().GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("failed to get user permissions", err)
+	}
+	user.Roles = roles
+	user.Permissions = slices.DeleteFunc(permissions, func(permission string) bool {
+		return !slices.Contains(pat.Scopes, permission)
+	})
+
+	if err := s.repo.Tokens().TouchToken(ctx, pat.ID, ip); err != nil {
+		return nil, nil, errors.InternalServerError("failed to record token use", err)
+	}
+
+	return user, pat, nil
+}
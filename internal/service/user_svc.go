@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Jonathan0823/auth-go/internal/errors"
 	"github.com/Jonathan0823/auth-go/internal/models"
@@ -11,6 +14,7 @@ import (
 )
 
 type UserService interface {
+	CheckUserStatus(ctx context.Context, id int) error
 	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetAllUsers(ctx context.Context) ([]*models.User, error)
@@ -28,6 +32,19 @@ func NewUserService(repo repository.Repository) UserService {
 	}
 }
 
+// CheckUserStatus is used by AuthMiddleware so that suspending an account
+// takes effect before its access tokens expire.
+func (s *userService) CheckUserStatus(ctx context.Context, id int) error {
+	user, err := s.repo.Users().GetUserByID(ctx, id)
+	if err != nil {
+		return errors.InternalServerError("failed to get user by id", err)
+	}
+	if user == nil {
+		return errors.Unauthorized("user not found", nil)
+	}
+	return ensureUserActive(user)
+}
+
 func (s *userService) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	data, err := s.repo.Users().GetUserByID(ctx, id)
 	if err != nil {
@@ -68,15 +85,60 @@ func (s *userService) UpdateUser(ctx context.Context, user models.UpdateUserRequ
 		return errors.Forbidden("you are not authorized to update this user", nil)
 	}
 
-	if err := s.repo.Users().UpdateUser(ctx, user); err != nil {
-		return errors.InternalServerError("failed to update user", err)
-	}
-	return nil
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		existing, err := u.Users().GetUserByID(ctx, user.ID)
+		if err != nil {
+			return errors.InternalServerError("failed to get user by id", err)
+		}
+		if existing == nil {
+			return errors.NotFound("user not found", nil)
+		}
+
+		if err := u.Users().UpdateUser(ctx, user); err != nil {
+			return errors.InternalServerError("failed to update user", err)
+		}
+		if existing.Email != user.Email {
+			if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserEmailChanged, map[string]any{
+				"id":             user.ID,
+				"email":          user.Email,
+				"previous_email": existing.Email,
+			}); err != nil {
+				return err
+			}
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			ActorID:    &currentUser.ID,
+			Action:     models.AuditUserUpdate,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(user.ID),
+		}, map[string]any{"username": user.Username, "email": user.Email, "avatar_url": user.AvatarURL})
+	})
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id int) error {
-	if err := s.repo.Users().DeleteUser(ctx, id); err != nil {
-		return errors.InternalServerError("failed to delete user", err)
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Users().DeleteUser(ctx, id); err != nil {
+			return errors.InternalServerError("failed to delete user", err)
+		}
+		if err := enqueueWebhookEvent(ctx, u.Webhooks(), models.WebhookUserDeleted, map[string]any{"id": id, "hard": true}); err != nil {
+			return err
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditUserDelete,
+			TargetType: "user",
+			TargetID:   strconv.Itoa(id),
+		}, nil)
+	})
+}
+
+// ensureUserActive rejects accounts whose status blocks access. A status
+// with an expiry stops applying once the expiry has passed.
+func ensureUserActive(user *models.User) error {
+	if user.Status == "" || user.Status == models.UserStatusActive {
+		return nil
+	}
+	if user.StatusExpiresAt != nil && time.Now().After(*user.StatusExpiresAt) {
+		return nil
 	}
-	return nil
+	return errors.Forbidden("account is "+strings.ReplaceAll(user.Status, "_", " "), nil)
 }
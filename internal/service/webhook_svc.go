@@ -0,0 +1,327 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	goerror "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/repository"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/google/uuid"
+)
+
+// WebhookSecretPrefix starts every webhook signing secret.
+const WebhookSecretPrefix = "whsec_"
+
+const (
+	webhookFanOutBatchSize   = 100
+	webhookDeliveryBatchSize = 20
+	webhookDeliveryTimeout   = 10 * time.Second
+	// webhookDeliveryLease keeps a claimed delivery from being sent twice
+	// while its request is in flight.
+	webhookDeliveryLease = time.Minute
+	webhookMaxAttempts   = 10
+	webhookBaseBackoff   = 30 * time.Second
+	webhookMaxBackoff    = 6 * time.Hour
+	webhookDeliveryLog   = 100
+)
+
+var webhookClient = &http.Client{Timeout: webhookDeliveryTimeout}
+
+type WebhookService interface {
+	CreateEndpoint(ctx context.Context, actorID int, req models.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, string, error)
+	GetEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	SetEndpointActive(ctx context.Context, id int, active bool) error
+	DeleteEndpoint(ctx context.Context, id int) error
+	GetDeliveries(ctx context.Context, endpointID int) ([]*models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, endpointID int, deliveryID int64) (*models.WebhookDelivery, error)
+	Dispatch(ctx context.Context) (int, error)
+}
+
+type webhookService struct {
+	repo repository.Repository
+}
+
+func NewWebhookService(repo repository.Repository) WebhookService {
+	return &webhookService{
+		repo: repo,
+	}
+}
+
+// CreateEndpoint returns the endpoint and its signing secret, which is only
+// shown once.
+func (s *webhookService) CreateEndpoint(ctx context.Context, actorID int, req models.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, string, error) {
+	endpointURL, err := url.Parse(req.URL)
+	if err != nil || (endpointURL.Scheme != "https" && endpointURL.Scheme != "http") || endpointURL.Host == "" {
+		return nil, "", errors.BadRequest("url must be an http or https URL", err)
+	}
+
+	secret, err := utils.GenerateOpaqueToken(WebhookSecretPrefix)
+	if err != nil {
+		return nil, "", errors.InternalServerError("failed to generate webhook secret", err)
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Active:      true,
+		Secret:      secret,
+		CreatedBy:   &actorID,
+		CreatedAt:   time.Now(),
+	}
+
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		id, err := u.Webhooks().CreateEndpoint(ctx, *endpoint)
+		if err != nil {
+			return errors.InternalServerError("failed to create webhook endpoint", err)
+		}
+		endpoint.ID = id
+
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditAdminWebhookCreate,
+			TargetType: "webhook_endpoint",
+			TargetID:   strconv.Itoa(id),
+		}, map[string]any{"url": endpoint.URL, "events": endpoint.Events})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return endpoint, secret, nil
+}
+
+func (s *webhookService) GetEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	endpoints, err := s.repo.Webhooks().GetEndpoints(ctx)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get webhook endpoints", err)
+	}
+	return endpoints, nil
+}
+
+// SetEndpointActive pauses or resumes an endpoint. Events raised while it is
+// paused are not delivered to it.
+func (s *webhookService) SetEndpointActive(ctx context.Context, id int, active bool) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Webhooks().SetEndpointActive(ctx, id, active); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("webhook endpoint not found", err)
+			}
+			return errors.InternalServerError("failed to update webhook endpoint", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditAdminWebhookUpdate,
+			TargetType: "webhook_endpoint",
+			TargetID:   strconv.Itoa(id),
+		}, map[string]any{"active": active})
+	})
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id int) error {
+	return s.repo.WithTx(ctx, func(u repository.UOW) error {
+		if err := u.Webhooks().DeleteEndpoint(ctx, id); err != nil {
+			if goerror.Is(err, sql.ErrNoRows) {
+				return errors.NotFound("webhook endpoint not found", err)
+			}
+			return errors.InternalServerError("failed to delete webhook endpoint", err)
+		}
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditAdminWebhookDelete,
+			TargetType: "webhook_endpoint",
+			TargetID:   strconv.Itoa(id),
+		}, nil)
+	})
+}
+
+// GetDeliveries returns the endpoint's 100 most recent deliveries.
+func (s *webhookService) GetDeliveries(ctx context.Context, endpointID int) ([]*models.WebhookDelivery, error) {
+	if err := s.ensureEndpointExists(ctx, endpointID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.repo.Webhooks().GetDeliveries(ctx, endpointID, webhookDeliveryLog)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get webhook deliveries", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a new delivery of the same event to the endpoint, leaving
+// the original in the log.
+func (s *webhookService) Redeliver(ctx context.Context, endpointID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.Webhooks().GetDelivery(ctx, endpointID, deliveryID)
+	if err != nil {
+		return nil, errors.InternalServerError("failed to get webhook delivery", err)
+	}
+	if delivery == nil {
+		return nil, errors.NotFound("webhook delivery not found", nil)
+	}
+
+	redelivery := &models.WebhookDelivery{
+		EndpointID:    delivery.EndpointID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	err = s.repo.WithTx(ctx, func(u repository.UOW) error {
+		id, err := u.Webhooks().CreateDelivery(ctx, *redelivery)
+		if err != nil {
+			return errors.InternalServerError("failed to create webhook delivery", err)
+		}
+		redelivery.ID = id
+
+		return recordAuditEvent(ctx, u.Audit(), models.AuditEvent{
+			Action:     models.AuditAdminWebhookRedeliver,
+			TargetType: "webhook_endpoint",
+			TargetID:   strconv.Itoa(endpointID),
+		}, map[string]any{"delivery_id": deliveryID, "redelivery_id": id, "event_id": delivery.EventID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redelivery, nil
+}
+
+// Dispatch fans new outbox events out to their endpoints and sends one batch
+// of due deliveries. It returns how many deliveries it attempted, so callers
+// can keep going while there is a backlog.
+func (s *webhookService) Dispatch(ctx context.Context) (int, error) {
+	if _, err := s.repo.Webhooks().FanOutEvents(ctx, webhookFanOutBatchSize); err != nil {
+		return 0, errors.InternalServerError("failed to fan out webhook events", err)
+	}
+
+	deliveries, err := s.repo.Webhooks().ClaimDueDeliveries(ctx, webhookDeliveryBatchSize, webhookDeliveryLease)
+	if err != nil {
+		return 0, errors.InternalServerError("failed to claim webhook deliveries", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(deliveries))
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	if err := goerror.Join(errs...); err != nil {
+		return len(deliveries), errors.InternalServerError("failed to record webhook delivery", err)
+	}
+	return len(deliveries), nil
+}
+
+// deliver posts delivery to its endpoint and records the outcome. Failures
+// are retried with exponential backoff until webhookMaxAttempts, after which
+// the delivery is dead-lettered.
+func (s *webhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+
+	statusCode, err := postWebhook(ctx, delivery)
+	if statusCode != 0 {
+		delivery.ResponseStatus = &statusCode
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = models.WebhookDeliveryDeadLetter
+		delivery.LastError = err.Error()
+	default:
+		delivery.Status = models.WebhookDeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(retryBackoff(delivery.Attempts, webhookBaseBackoff, webhookMaxBackoff))
+	}
+
+	return s.repo.Webhooks().UpdateDelivery(ctx, *delivery)
+}
+
+// postWebhook sends the signed payload and returns the response status. Any
+// status outside 2xx is an error.
+func postWebhook(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "auth-go-webhooks")
+	req.Header.Set("Webhook-Id", delivery.EventID.String())
+	req.Header.Set("Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Webhook-Signature", utils.SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryBackoff doubles the wait after every failed attempt, starting at base
+// and capped at max.
+func retryBackoff(attempts int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+	return min(backoff, max)
+}
+
+func (s *webhookService) ensureEndpointExists(ctx context.Context, id int) error {
+	endpoint, err := s.repo.Webhooks().GetEndpointByID(ctx, id)
+	if err != nil {
+		return errors.InternalServerError("failed to get webhook endpoint", err)
+	}
+	if endpoint == nil {
+		return errors.NotFound("webhook endpoint not found", nil)
+	}
+	return nil
+}
+
+// enqueueWebhookEvent adds an event to the outbox through webhooks, which
+// should belong to the transaction making the change so that the event is
+// only sent if the change commits.
+func enqueueWebhookEvent(ctx context.Context, webhooks repository.WebhookRepository, eventType string, data any) error {
+	event := models.WebhookEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.InternalServerError("failed to encode webhook event", err)
+	}
+
+	if err := webhooks.CreateOutboxEvent(ctx, event, payload); err != nil {
+		return errors.InternalServerError("failed to queue webhook event", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/utils"
+	"github.com/google/uuid"
+)
+
+func newTestWebhookService(url string) (*webhookService, *memRepository) {
+	repo := newMemRepository()
+	repo.store.endpoints[1] = true
+	repo.store.deliveries[1] = models.WebhookDelivery{
+		ID:            1,
+		EndpointID:    1,
+		EventID:       uuid.New(),
+		EventType:     models.WebhookUserRegistered,
+		Payload:       []byte(`{"type":"user.registered"}`),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		URL:           url,
+		Secret:        "whsec_test",
+	}
+	return &webhookService{repo: repo}, repo
+}
+
+func TestDispatchSignsTheDelivery(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	svc, repo := newTestWebhookService(receiver.URL)
+	sent, err := svc.Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 1 {
+		t.Fatalf("sent = %d, want 1", sent)
+	}
+
+	r, body := <-received, <-bodies
+	delivery := repo.store.deliveries[1]
+	if got := r.Header.Get("Webhook-Id"); got != delivery.EventID.String() {
+		t.Errorf("Webhook-Id = %q, want %q", got, delivery.EventID)
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get("Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("Webhook-Timestamp: %v", err)
+	}
+	if got, want := r.Header.Get("Webhook-Signature"), utils.SignWebhook("whsec_test", timestamp, body); got != want {
+		t.Errorf("Webhook-Signature = %q, want %q", got, want)
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want succeeded after 1", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDispatchRetriesFailedDeliveriesUntilDeadLettered(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	svc, repo := newTestWebhookService(receiver.URL)
+	ctx := context.Background()
+
+	before := time.Now()
+	if _, err := svc.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	delivery := repo.store.deliveries[1]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("delivery = %s after %d attempts, want pending after 1", delivery.Status, delivery.Attempts)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("response status = %v, want 503", delivery.ResponseStatus)
+	}
+	if !delivery.NextAttemptAt.After(before.Add(webhookBaseBackoff - time.Second)) {
+		t.Errorf("next attempt at %v, want about %v from now", delivery.NextAttemptAt, webhookBaseBackoff)
+	}
+	if sent, _ := svc.Dispatch(ctx); sent != 0 {
+		t.Errorf("sent %d deliveries before the retry was due", sent)
+	}
+
+	for attempt := 2; attempt <= webhookMaxAttempts; attempt++ {
+		delivery := repo.store.deliveries[1]
+		delivery.NextAttemptAt = time.Now()
+		repo.store.deliveries[1] = delivery
+		if sent, err := svc.Dispatch(ctx); err != nil || sent != 1 {
+			t.Fatalf("attempt %d: sent %d, %v", attempt, sent, err)
+		}
+	}
+
+	delivery = repo.store.deliveries[1]
+	if delivery.Status != models.WebhookDeliveryDeadLetter || delivery.Attempts != webhookMaxAttempts {
+		t.Fatalf("delivery = %s after %d attempts, want dead after %d", delivery.Status, delivery.Attempts, webhookMaxAttempts)
+	}
+	if delivery.LastError == "" {
+		t.Error("dead letter has no last error")
+	}
+	delivery.NextAttemptAt = time.Now()
+	repo.store.deliveries[1] = delivery
+	if sent, _ := svc.Dispatch(ctx); sent != 0 {
+		t.Errorf("sent a dead-lettered delivery again")
+	}
+}
+
+func TestSetEndpointActiveRecordsAnAuditEvent(t *testing.T) {
+	svc, repo := newTestWebhookService("")
+
+	if err := svc.SetEndpointActive(context.Background(), 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if repo.store.endpoints[1] {
+		t.Error("endpoint is still active")
+	}
+	if !slices.Equal(repo.store.audit, []string{models.AuditAdminWebhookUpdate}) {
+		t.Errorf("audit = %v", repo.store.audit)
+	}
+
+	err := svc.SetEndpointActive(context.Background(), 2, false)
+	wantStatus(t, err, http.StatusNotFound)
+}
+
+func TestRedeliverRecordsAnAuditEvent(t *testing.T) {
+	svc, repo := newTestWebhookService("")
+	ctx := context.Background()
+
+	redelivery, err := svc.Redeliver(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.store.deliveries[redelivery.ID]; got.EventID != repo.store.deliveries[1].EventID || got.Status != models.WebhookDeliveryPending {
+		t.Errorf("redelivery = %+v", got)
+	}
+	if !slices.Equal(repo.store.audit, []string{models.AuditAdminWebhookRedeliver}) {
+		t.Errorf("audit = %v", repo.store.audit)
+	}
+
+	_, err = svc.Redeliver(ctx, 2, 1)
+	wantStatus(t, err, http.StatusNotFound)
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// EmailDispatchInterval is how often the dispatcher looks for queued emails
+// and due retries when it is idle.
+const EmailDispatchInterval = 2 * time.Second
+
+// EmailDispatcher sends queued emails until its context ends. Any number of
+// replicas may run one; claimed emails are leased.
+type EmailDispatcher struct {
+	svc      service.Service
+	interval time.Duration
+}
+
+func NewEmailDispatcher(svc service.Service, interval time.Duration) *EmailDispatcher {
+	return &EmailDispatcher{
+		svc:      svc,
+		interval: interval,
+	}
+}
+
+func (d *EmailDispatcher) Run(ctx context.Context) {
+	poll(ctx, d.interval, "email dispatch", d.svc.Emails().Dispatch)
+}
@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// schedulerLockID is the advisory lock held by the replica that runs the
+// scheduled jobs.
+const schedulerLockID = 7_105_312_003
+
+// LeaderElectionInterval is how often a replica that is not the leader tries
+// to become it.
+const LeaderElectionInterval = 30 * time.Second
+
+// Job is a task the scheduler runs every Interval. Run returns how many rows
+// it changed and a result that is recorded with the run.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (int, any, error)
+}
+
+// Scheduler runs jobs on one replica at a time. Every replica runs a
+// Scheduler, and the one holding a Postgres advisory lock is the leader. The
+// lock belongs to a database session, so if the leader dies or loses its
+// connection another replica takes over at its next election.
+type Scheduler struct {
+	db       *sql.DB
+	svc      service.Service
+	jobs     []Job
+	instance string
+}
+
+func NewScheduler(db *sql.DB, svc service.Service, jobs ...Job) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		svc:      svc,
+		jobs:     jobs,
+		instance: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(LeaderElectionInterval)
+	defer ticker.Stop()
+
+	for {
+		conn, err := s.elect(ctx)
+		if err != nil && ctx.Err() == nil {
+			logError("scheduler leader election failed", err)
+		}
+		if conn != nil {
+			log.Printf("scheduler: %s is the leader", s.instance)
+			s.lead(ctx, conn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elect returns the connection holding the scheduler lock, or nil when
+// another replica holds it.
+func (s *Scheduler) elect(ctx context.Context) (*sql.Conn, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", schedulerLockID).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+// lead runs every job on becoming leader and then every Interval, until ctx
+// ends or the connection holding the lock is lost.
+func (s *Scheduler) lead(ctx context.Context, conn *sql.Conn) {
+	defer func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schedulerLockID)
+		conn.Close()
+	}()
+
+	next := make([]time.Time, len(s.jobs))
+	for {
+		if err := conn.PingContext(ctx); err != nil {
+			if ctx.Err() == nil {
+				logError("scheduler lost its leader lock", err)
+			}
+			return
+		}
+
+		for i, job := range s.jobs {
+			if time.Now().Before(next[i]) {
+				continue
+			}
+			s.runJob(ctx, job)
+			next[i] = time.Now().Add(job.Interval)
+		}
+
+		earliest := next[0]
+		for _, at := range next[1:] {
+			if at.Before(earliest) {
+				earliest = at
+			}
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runJob runs job and records the outcome in job_runs.
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	run := models.JobRun{
+		Job:       job.Name,
+		Instance:  s.instance,
+		Status:    models.JobRunSucceeded,
+		StartedAt: time.Now(),
+	}
+
+	rows, result, err := job.Run(ctx)
+	run.FinishedAt = time.Now()
+	run.RowsAffected = int64(rows)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logError(job.Name+" failed", err)
+		run.Status = models.JobRunFailed
+		run.Error = errorMessage(err)
+	}
+	if result != nil {
+		if run.Result, err = json.Marshal(result); err != nil {
+			logError("failed to encode result of "+job.Name, err)
+		}
+	}
+
+	if err := s.svc.Maintenance().RecordJobRun(ctx, run); err != nil {
+		logError("failed to record run of "+job.Name, err)
+	}
+}
+
+// PurgeJob deletes expired link token nonces, and refresh tokens,
+// invitations and job runs past retention.
+func PurgeJob(svc service.Service, interval, retention time.Duration) Job {
+	return Job{
+		Name:     "purge-expired",
+		Interval: interval,
+		Run: func(ctx context.Context) (int, any, error) {
+			report, err := svc.Maintenance().PurgeExpired(ctx, retention)
+			if err != nil {
+				return 0, nil, err
+			}
+			return report.Total(), report, nil
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/service"
+)
+
+// WebhookDispatchInterval is how often the dispatcher looks for new events
+// and due retries when it is idle.
+const WebhookDispatchInterval = 5 * time.Second
+
+// WebhookDispatcher delivers queued webhook events until its context ends.
+// Any number of replicas may run one; claimed deliveries are leased.
+type WebhookDispatcher struct {
+	svc      service.Service
+	interval time.Duration
+}
+
+func NewWebhookDispatcher(svc service.Service, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		svc:      svc,
+		interval: interval,
+	}
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	poll(ctx, d.interval, "webhook dispatch", d.svc.Webhooks().Dispatch)
+}
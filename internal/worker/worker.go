@@ -0,0 +1,51 @@
+// Package worker runs the background jobs of the server process.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Jonathan0823/auth-go/internal/errors"
+)
+
+// poll calls dispatch every interval until ctx ends. While dispatch reports
+// work done it is called again right away, so a backlog drains without
+// waiting for the next tick.
+func poll(ctx context.Context, interval time.Duration, name string, dispatch func(context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			done, err := dispatch(ctx)
+			if err != nil {
+				logError(name+" failed", err)
+				break
+			}
+			if done == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logError logs err together with the cause the service layer wrapped, which
+// the error message alone leaves out.
+func logError(message string, err error) {
+	log.Printf("%s: %s", message, errorMessage(err))
+}
+
+func errorMessage(err error) string {
+	if appErr, ok := err.(*errors.Error); ok && appErr.Err != nil {
+		return fmt.Sprintf("%s: %v", appErr.Message, appErr.Err)
+	}
+	return err.Error()
+}
@@ -1,41 +1,136 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/Jonathan0823/auth-go/config"
+	"github.com/Jonathan0823/auth-go/internal/cli"
 	"github.com/Jonathan0823/auth-go/internal/handler"
+	"github.com/Jonathan0823/auth-go/internal/mailer"
+	"github.com/Jonathan0823/auth-go/internal/migrate"
 	"github.com/Jonathan0823/auth-go/internal/repository"
 	"github.com/Jonathan0823/auth-go/internal/routes"
 	"github.com/Jonathan0823/auth-go/internal/service"
+	"github.com/Jonathan0823/auth-go/internal/worker"
+	"github.com/Jonathan0823/auth-go/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	if os.Getenv("ENVIRONMENT") != "production" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("Error loading .env file")
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	// Help and writing a new migration do not need a database.
+	if args[0] == "help" || (args[0] == "migrate" && len(args) > 1 && args[1] == "create") {
+		if err := cli.Run(context.Background(), nil, nil, args); err != nil {
+			log.Fatal(err)
 		}
+		return
 	}
 
-	config.InitOAuth()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.UseSecrets(utils.Secrets{
+		JWTAccess:  cfg.Secrets.JWTAccess,
+		JWTRefresh: cfg.Secrets.JWTRefresh,
+		LinkToken:  cfg.Secrets.LinkToken,
+		Session:    cfg.Secrets.Session,
+	})
+	utils.UseRedirects(cfg.Server.FrontendURL, cfg.Server.RedirectAllowlist)
 
-	db := config.InitDB()
+	db := config.InitDB(cfg.Database)
 	defer db.Close()
 
+	// migrate manages the schema itself; everything else expects it current.
+	if args[0] != "migrate" && cfg.Database.MigrateOnStart {
+		migrator, err := migrate.New(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		applied, err := migrator.Up(context.Background(), 0)
+		if err != nil {
+			log.Fatal("Error migrating the database: ", err)
+		}
+		for _, m := range applied {
+			log.Printf("applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	repo := repository.NewRepository(db)
+	var authBackends []service.AuthBackend
+	if cfg.LDAP != nil {
+		authBackends = append(authBackends, service.NewLDAPBackend(repo, *cfg.LDAP))
+	}
+	mail, err := mailer.New(cfg.Mailer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	templates, err := mailer.NewTemplates(cfg.Mailer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	svc := service.NewService(repo,
+		service.WithAuthBackends(authBackends...),
+		service.WithMailer(mail),
+		service.WithEmailTemplates(templates),
+	)
+
+	if args[0] != "serve" {
+		if err := cli.Run(context.Background(), db, svc, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	config.InitOAuth(cfg)
+	config.InitSAML(cfg)
+
+	// Workers run on their own context so they keep going while the server
+	// drains; they stop once it has, and the database closes last.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+	startWorker(worker.NewWebhookDispatcher(svc, worker.WebhookDispatchInterval).Run)
+	startWorker(worker.NewEmailDispatcher(svc, worker.EmailDispatchInterval).Run)
+	if cfg.Scheduler.Enabled {
+		startWorker(worker.NewScheduler(db, svc,
+			worker.PurgeJob(svc, cfg.Scheduler.PurgeInterval, cfg.Scheduler.PurgeRetention),
+		).Run)
+	}
+
 	r := gin.New()
 	r.Use(gin.Logger())
-	repo := repository.NewRepository(db)
-	svc := service.NewService(repo)
-	mainHandler := handler.NewMainHandler(svc)
+	r.Use(cfg.Server.CORS())
+	mainHandler := handler.NewMainHandler(svc, cfg.Server)
+
+	routes.RegisterRoutes(r, mainHandler, svc)
 
-	routes.RegisterRoutes(r, mainHandler)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	serveErr := cfg.Server.Serve(ctx, r)
 
-	config.NewServer().InitServer(r)
+	log.Println("Stopping background workers")
+	stopWorkers()
+	workers.Wait()
+	db.Close()
 
-	fmt.Println("Server is running on port 8080")
+	if serveErr != nil {
+		log.Fatal(serveErr)
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/Jonathan0823/auth-go/internal/models"
+)
+
+// auditTimeLayout formats audit timestamps the way they are stored, without
+// a zone, so the hash does not depend on the driver's time location.
+const auditTimeLayout = "2006-01-02T15:04:05.000000"
+
+// HashAuditEvent returns the chain hash of event, covering its PrevHash and
+// every recorded field. Metadata must be the JSON text stored by the
+// database for the hash to verify later.
+func HashAuditEvent(event models.AuditEvent) (string, error) {
+	metadata := event.Metadata
+	if len(metadata) == 0 {
+		metadata = json.RawMessage("{}")
+	}
+
+	data, err := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		ActorID    *int            `json:"actor_id"`
+		Action     string          `json:"action"`
+		TargetType string          `json:"target_type"`
+		TargetID   string          `json:"target_id"`
+		IPAddress  string          `json:"ip_address"`
+		UserAgent  string          `json:"user_agent"`
+		RequestID  string          `json:"request_id"`
+		Metadata   json.RawMessage `json:"metadata"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		PrevHash:   event.PrevHash,
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IPAddress:  event.IPAddress,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Metadata:   metadata,
+		CreatedAt:  event.CreatedAt.Format(auditTimeLayout),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -3,8 +3,7 @@ package utils
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/Jonathan0823/auth-go/internal/models"
@@ -12,13 +11,16 @@ import (
 	"github.com/google/uuid"
 )
 
+// GenerateJWT signs an access, refresh or impersonation token for user.
+// Impersonation tokens are short-lived access tokens that carry user.Actor in
+// an RFC 8693 act claim.
 func GenerateJWT(user models.User, jwtType string) (jwtToken string, jti string, err error) {
 	var secretKey []byte
 	switch jwtType {
-	case "access":
-		secretKey = []byte(os.Getenv("JWT_ACCESS_SECRET"))
+	case "access", "impersonation":
+		secretKey = []byte(secrets.JWTAccess)
 	case "refresh":
-		secretKey = []byte(os.Getenv("JWT_REFRESH_SECRET"))
+		secretKey = []byte(secrets.JWTRefresh)
 	}
 
 	var expirationTime time.Time
@@ -27,10 +29,12 @@ func GenerateJWT(user models.User, jwtType string) (jwtToken string, jti string,
 		expirationTime = time.Now().Add(time.Minute * 15)
 	case "refresh":
 		expirationTime = time.Now().Add(time.Hour * 24 * 7)
+	case "impersonation":
+		expirationTime = time.Now().Add(ImpersonationTTL)
 	}
 
 	if len(secretKey) == 0 {
-		log.Fatal("JWT secret key is not set in the environment variables")
+		return "", "", fmt.Errorf("no signing key for %s tokens", jwtType)
 	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
@@ -43,6 +47,19 @@ func GenerateJWT(user models.User, jwtType string) (jwtToken string, jti string,
 	claims["username"] = user.Username
 	claims["email"] = user.Email
 	claims["exp"] = expirationTime.Unix()
+	if user.ActiveOrgID != 0 {
+		claims["org_id"] = user.ActiveOrgID
+	}
+	if jwtType != "refresh" {
+		claims["roles"] = user.Roles
+		claims["permissions"] = user.Permissions
+	}
+	if jwtType == "impersonation" && user.Actor != nil {
+		claims["act"] = map[string]any{
+			"sub":   strconv.Itoa(user.Actor.ID),
+			"email": user.Actor.Email,
+		}
+	}
 
 	tokenString, err := token.SignedString(secretKey)
 	if err != nil {
@@ -52,17 +69,20 @@ func GenerateJWT(user models.User, jwtType string) (jwtToken string, jti string,
 	return tokenString, id, nil
 }
 
+// ImpersonationTTL is how long an impersonation token stays valid.
+const ImpersonationTTL = 10 * time.Minute
+
 func ValidateJWT(tokenString string, jwtType string) (jwt.MapClaims, error) {
 	var secretKey []byte
 	switch jwtType {
 	case "access":
-		secretKey = []byte(os.Getenv("JWT_ACCESS_SECRET"))
+		secretKey = []byte(secrets.JWTAccess)
 	case "refresh":
-		secretKey = []byte(os.Getenv("JWT_REFRESH_SECRET"))
+		secretKey = []byte(secrets.JWTRefresh)
 	}
 
 	if len(secretKey) == 0 {
-		log.Fatal("JWT secret key is not set in the environment variables")
+		return nil, fmt.Errorf("no signing key for %s tokens", jwtType)
 	}
 
 	claims := jwt.MapClaims{}
@@ -82,3 +102,39 @@ func ValidateJWT(tokenString string, jwtType string) (jwt.MapClaims, error) {
 
 	return claims, nil
 }
+
+// PrincipalClaims builds the claims AuthMiddleware stores for principals that
+// do not authenticate with a JWT, shaped like the claims ValidateJWT returns.
+func PrincipalClaims(user models.User, principalType, jti string, scopes []string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"id":             float64(user.ID),
+		"jti":            jti,
+		"username":       user.Username,
+		"email":          user.Email,
+		"roles":          anySlice(user.Roles),
+		"permissions":    anySlice(user.Permissions),
+		"scopes":         anySlice(scopes),
+		"principal_type": principalType,
+	}
+}
+
+// ServiceAccountClaims builds the claims AuthMiddleware stores for a service
+// account authenticated with an API key.
+func ServiceAccountClaims(principal models.Principal) jwt.MapClaims {
+	return jwt.MapClaims{
+		"id":             float64(principal.ID),
+		"name":           principal.Name,
+		"org_id":         float64(principal.OrgID),
+		"permissions":    anySlice(principal.Permissions),
+		"scopes":         anySlice(principal.Scopes),
+		"principal_type": models.PrincipalServiceAccount,
+	}
+}
+
+func anySlice(values []string) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
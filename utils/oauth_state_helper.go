@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OAuthState is carried through the provider round trip in the state
+// parameter. Nonce must match the one stored in the browser's flow session.
+type OAuthState struct {
+	Nonce     string `json:"n"`
+	Provider  string `json:"p"`
+	ReturnTo  string `json:"r,omitempty"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// SignOAuthState encodes the state as payload.signature, signed with
+// SESSION_SECRET.
+func SignOAuthState(state OAuthState) (string, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signOAuthPayload(payload), nil
+}
+
+func VerifyOAuthState(token string) (OAuthState, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return OAuthState{}, fmt.Errorf("invalid state")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signOAuthPayload(payload))) {
+		return OAuthState{}, fmt.Errorf("invalid state signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return OAuthState{}, fmt.Errorf("invalid state: %v", err)
+	}
+
+	var state OAuthState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return OAuthState{}, fmt.Errorf("invalid state: %v", err)
+	}
+
+	if time.Now().Unix() > state.ExpiresAt {
+		return OAuthState{}, fmt.Errorf("state expired")
+	}
+	return state, nil
+}
+
+// RandomToken returns n random bytes encoded as unpadded base64url.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge derives the S256 code challenge for a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func signOAuthPayload(payload string) string {
+	mac := hmac.New(sha256.New, []byte(secrets.Session))
+	mac.Write([]byte("oauth-state:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
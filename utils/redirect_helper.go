@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+var (
+	frontendURL       = "http://localhost:3000"
+	redirectAllowlist []string
+)
+
+// UseRedirects sets the frontend URL and the extra origins return_to may
+// point at.
+func UseRedirects(frontend string, allowlist []string) {
+	if frontend != "" {
+		frontendURL = frontend
+	}
+	redirectAllowlist = allowlist
+}
+
+// FrontendURL is where browser flows land when no return_to is given.
+func FrontendURL() string {
+	return frontendURL
+}
+
+// ResolveRedirectURL returns returnTo when its origin is allow-listed and
+// falls back to FrontendURL otherwise.
+func ResolveRedirectURL(returnTo string) string {
+	if returnTo != "" && IsAllowedRedirect(returnTo) {
+		return returnTo
+	}
+	return FrontendURL()
+}
+
+// IsAllowedRedirect checks the origin of rawURL against the frontend URL and
+// the redirect allowlist.
+func IsAllowedRedirect(rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return false
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return false
+	}
+
+	allowed := append([]string{frontendURL}, redirectAllowlist...)
+	for _, entry := range allowed {
+		origin, err := url.Parse(entry)
+		if err != nil || origin.Host == "" {
+			continue
+		}
+		if strings.EqualFold(origin.Scheme, target.Scheme) && strings.EqualFold(origin.Host, target.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendQuery adds key=value to the query string of rawURL.
+func AppendQuery(rawURL, key, value string) string {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := target.Query()
+	query.Set(key, value)
+	target.RawQuery = query.Encode()
+	return target.String()
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"strings"
+)
+
+// RequestMetadata describes the HTTP request a context belongs to. Audit
+// events fall back to it for the fields their caller leaves empty.
+type RequestMetadata struct {
+	RequestID string
+	IPAddress string
+	UserAgent string
+	ActorID   *int
+	// ServiceAccountID is set instead of ActorID for API key requests.
+	ServiceAccountID *int
+}
+
+type requestMetadataKey struct{}
+
+func WithRequestMetadata(ctx context.Context, metadata RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, metadata)
+}
+
+// GetRequestMetadata returns the zero RequestMetadata outside of a request,
+// for example in CLI commands.
+func GetRequestMetadata(ctx context.Context) RequestMetadata {
+	metadata, _ := ctx.Value(requestMetadataKey{}).(RequestMetadata)
+	return metadata
+}
+
+// PreferredLocale returns the first language of an Accept-Language header,
+// or an empty string when there is none.
+func PreferredLocale(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	locale, _, _ := strings.Cut(first, ";")
+	locale = strings.TrimSpace(locale)
+	if locale == "*" || len(locale) > 35 {
+		return ""
+	}
+	return locale
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// Secrets are the keys tokens and cookies are signed with.
+type Secrets struct {
+	JWTAccess  string
+	JWTRefresh string
+	LinkToken  string
+	Session    string
+}
+
+var secrets Secrets
+
+// UseSecrets sets the signing keys at startup. An empty LinkToken is derived
+// from JWTAccess, which keeps link tokens from validating as access tokens.
+func UseSecrets(s Secrets) {
+	if s.LinkToken == "" && s.JWTAccess != "" {
+		mac := hmac.New(sha256.New, []byte(s.JWTAccess))
+		mac.Write([]byte("auth-go link tokens"))
+		s.LinkToken = string(mac.Sum(nil))
+	}
+	secrets = s
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// GenerateOpaqueToken returns prefix followed by 32 random bytes. Only its
+// HashToken digest should be stored.
+func GenerateOpaqueToken(prefix string) (string, error) {
+	random, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return prefix + random, nil
+}
+
+// HashToken hashes a high-entropy opaque token for storage and lookup.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignWebhook returns the v1 signature of a webhook body: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint secret.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -2,12 +2,16 @@ package utils
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 
 	"github.com/Jonathan0823/auth-go/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// GetUser returns the authenticated user. It fails for requests made by a
+// service account, use GetPrincipal where those are accepted.
 func GetUser(c *gin.Context) (models.User, error) {
 	claims, exists := c.Get("user")
 	if !exists {
@@ -18,10 +22,113 @@ func GetUser(c *gin.Context) (models.User, error) {
 	if !ok {
 		return models.User{}, fmt.Errorf("invalid token claims")
 	}
+	if mapClaims["principal_type"] == models.PrincipalServiceAccount {
+		return models.User{}, fmt.Errorf("request is authenticated as a service account")
+	}
+
+	orgID, _ := mapClaims["org_id"].(float64)
+	user := models.User{
+		ID:          int(mapClaims["id"].(float64)),
+		Username:    mapClaims["username"].(string),
+		Email:       mapClaims["email"].(string),
+		Roles:       claimStrings(mapClaims["roles"]),
+		Permissions: claimStrings(mapClaims["permissions"]),
+		ActiveOrgID: int(orgID),
+	}
+
+	if act, ok := mapClaims["act"].(map[string]any); ok {
+		sub, _ := act["sub"].(string)
+		actorID, _ := strconv.Atoi(sub)
+		email, _ := act["email"].(string)
+		user.Actor = &models.Actor{ID: actorID, Email: email}
+	}
+
+	return user, nil
+}
+
+// GetPrincipalType reports how the request authenticated, models.PrincipalUser
+// for session tokens.
+func GetPrincipalType(c *gin.Context) string {
+	claims, exists := c.Get("user")
+	if !exists {
+		return ""
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	if principalType, ok := mapClaims["principal_type"].(string); ok {
+		return principalType
+	}
+	return models.PrincipalUser
+}
+
+// GetPrincipal returns whoever authenticated the request, user or service
+// account.
+func GetPrincipal(c *gin.Context) (models.Principal, error) {
+	claims, exists := c.Get("user")
+	if !exists {
+		return models.Principal{}, fmt.Errorf("principal is not found")
+	}
+
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return models.Principal{}, fmt.Errorf("invalid token claims")
+	}
+
+	id, _ := mapClaims["id"].(float64)
+	orgID, _ := mapClaims["org_id"].(float64)
+	principal := models.Principal{
+		Type:        GetPrincipalType(c),
+		ID:          int(id),
+		OrgID:       int(orgID),
+		Permissions: claimStrings(mapClaims["permissions"]),
+		Scopes:      claimStrings(mapClaims["scopes"]),
+	}
+	if principal.Type == models.PrincipalServiceAccount {
+		principal.Name, _ = mapClaims["name"].(string)
+	} else {
+		principal.Name, _ = mapClaims["username"].(string)
+	}
+
+	return principal, nil
+}
+
+// GetTokenID returns the jti of the authenticated access token.
+func GetTokenID(c *gin.Context) string {
+	claims, exists := c.Get("user")
+	if !exists {
+		return ""
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	jti, _ := mapClaims["jti"].(string)
+	return jti
+}
 
-	return models.User{
-		ID:       int(mapClaims["id"].(float64)),
-		Username: mapClaims["username"].(string),
-		Email:    mapClaims["email"].(string),
-	}, nil
+// HasPermission reports whether the authenticated principal's token grants
+// permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	principal, err := GetPrincipal(c)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(principal.Permissions, permission)
+}
+
+func claimStrings(claim any) []string {
+	values, ok := claim.([]any)
+	if !ok {
+		return nil
+	}
+
+	var result []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
 }
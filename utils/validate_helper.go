@@ -71,6 +71,19 @@ func splitJSONTag(tag string) string {
 	return tag
 }
 
+func BindQueryWithValidation(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return false
+	}
+
+	if validationErrors := ValidateStruct(obj); len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErrors})
+		return false
+	}
+	return true
+}
+
 func BindJSONWithValidation(c *gin.Context, obj any) bool {
 	if err := c.ShouldBindJSON(obj); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})